@@ -0,0 +1,180 @@
+package bot
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/azaky/cpbot/logging"
+	"github.com/azaky/cpbot/util"
+)
+
+// AdminHandler returns the handler of the admin API, to be mounted at
+// /admin/. Every request must be authenticated with token as a bearer token.
+//
+//	GET  /admin/chats            -> list chats
+//	GET  /admin/chats/{id}       -> show settings of a chat
+//	PUT  /admin/chats/{id}       -> update settings of a chat, only the given fields are changed
+//	POST /admin/chats/{id}/push  -> push {"text": "..."}, or the upcoming contests if empty, to a chat
+//	GET  /admin/timers           -> list scheduled timers
+//	POST /admin/broadcast        -> push {"text": "..."} to every chat, see adminBroadcastRequest
+func (b *LineBot) AdminHandler(token string) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/admin/chats", b.adminListChats)
+	mux.HandleFunc("/admin/chats/", b.adminChat)
+	mux.HandleFunc("/admin/timers", b.adminListTimers)
+	mux.HandleFunc("/admin/broadcast", b.adminBroadcast)
+	mux.HandleFunc("/admin/quota", b.adminGetQuota)
+	return adminAuth(token, mux)
+}
+
+func adminAuth(token string, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		auth := req.Header.Get("Authorization")
+		if token == "" || !strings.HasPrefix(auth, "Bearer ") ||
+			subtle.ConstantTimeCompare([]byte(strings.TrimPrefix(auth, "Bearer ")), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeAdminError(w, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+		h.ServeHTTP(w, req)
+	})
+}
+
+func writeAdminJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeAdminError(w http.ResponseWriter, status int, message string) {
+	writeAdminJSON(w, status, map[string]string{"error": message})
+}
+
+func (b *LineBot) adminListChats(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		writeAdminError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	chats, err := b.repo.GetUsers()
+	if err != nil {
+		adminLog.WithError(err).Error("Error getting chats")
+		writeAdminError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeAdminJSON(w, http.StatusOK, map[string]interface{}{"chats": chats})
+}
+
+type adminChatResponse struct {
+	ID       string           `json:"id"`
+	Settings exportedSettings `json:"settings"`
+	Admins   []string         `json:"admins,omitempty"`
+}
+
+type adminPushRequest struct {
+	Text string `json:"text"`
+}
+
+func (b *LineBot) adminChat(w http.ResponseWriter, req *http.Request) {
+	parts := strings.SplitN(strings.TrimPrefix(req.URL.Path, "/admin/chats/"), "/", 2)
+	chat := parts[0]
+	if _, err := util.StringToLineEventSource(chat); err != nil {
+		writeAdminError(w, http.StatusNotFound, fmt.Sprintf("invalid chat id: %s", chat))
+		return
+	}
+
+	switch {
+	case len(parts) == 1 && req.Method == http.MethodGet:
+		b.adminGetChat(w, chat)
+	case len(parts) == 1 && req.Method == http.MethodPut:
+		b.adminUpdateChat(w, req, chat)
+	case len(parts) == 2 && parts[1] == "push" && req.Method == http.MethodPost:
+		b.adminPush(w, req, chat)
+	case len(parts) == 2 && parts[1] != "push":
+		writeAdminError(w, http.StatusNotFound, "not found")
+	default:
+		writeAdminError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+func (b *LineBot) adminGetChat(w http.ResponseWriter, chat string) {
+	settings, err := b.exportSettings(chat)
+	if err != nil {
+		logging.WithChat(adminLog, chat).WithError(err).Error("Error getting settings")
+		writeAdminError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	admins, _ := b.repo.GetAdmins(chat)
+	writeAdminJSON(w, http.StatusOK, adminChatResponse{ID: chat, Settings: settings, Admins: admins})
+}
+
+func (b *LineBot) adminUpdateChat(w http.ResponseWriter, req *http.Request, chat string) {
+	var settings exportedSettings
+	if err := json.NewDecoder(req.Body).Decode(&settings); err != nil {
+		writeAdminError(w, http.StatusBadRequest, fmt.Sprintf("invalid body: %s", err.Error()))
+		return
+	}
+	if err := settings.validate(b.maxDuration()); err != nil {
+		writeAdminError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if len(settings.Daily) > lineMaxDailyTimes {
+		writeAdminError(w, http.StatusBadRequest, fmt.Sprintf("at most %d daily reminder times are allowed", lineMaxDailyTimes))
+		return
+	}
+
+	if err := b.updateSettings(chat, settings); err != nil {
+		logging.WithChat(adminLog, chat).WithError(err).Error("Error updating settings")
+		writeAdminError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	logging.WithChat(adminLog, chat).Info("Updated settings")
+	b.adminGetChat(w, chat)
+}
+
+func (b *LineBot) adminPush(w http.ResponseWriter, req *http.Request, chat string) {
+	var body adminPushRequest
+	if req.ContentLength != 0 {
+		if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+			writeAdminError(w, http.StatusBadRequest, fmt.Sprintf("invalid body: %s", err.Error()))
+			return
+		}
+	}
+
+	messages := splitMessage(strings.Split(body.Text, "\n"), b.config.MaxMessageLength)
+	if body.Text == "" {
+		tz, _ := b.repo.GetTimezone(chat)
+		window, _ := b.repo.GetDailyWindow(chat)
+		from, to, header := dailyWindowRange(window, b.maxDuration(), b.clock.Now(), tz)
+		var err error
+		messages, err = generateUpcomingContestsMessage(b.clistService, from, to, tz, header, b.config.MaxMessageLength)
+		if err != nil {
+			writeAdminError(w, http.StatusBadGateway, fmt.Sprintf("error getting contests: %s", err.Error()))
+			return
+		}
+	}
+
+	eventSource, _ := util.StringToLineEventSource(chat)
+	if err := b.push(eventSource, pushHigh, messages...); err != nil {
+		writeAdminError(w, http.StatusBadGateway, fmt.Sprintf("error pushing: %s", err.Error()))
+		return
+	}
+	logging.WithChat(adminLog, chat).Infof("Pushed %d messages", len(messages))
+	writeAdminJSON(w, http.StatusOK, map[string]interface{}{"pushed": len(messages)})
+}
+
+func (b *LineBot) adminListTimers(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		writeAdminError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	timers, next := b.scheduler.pending()
+	res := map[string]interface{}{"timers": timers}
+	if !next.IsZero() {
+		res["period_end"] = next.Format(time.RFC3339)
+	}
+	writeAdminJSON(w, http.StatusOK, res)
+}
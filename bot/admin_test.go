@@ -0,0 +1,134 @@
+package bot
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/azaky/cpbot/util"
+)
+
+const testAdminToken = "secret"
+
+func newTestAdminHandler() (http.Handler, *pushRecorder) {
+	clock := util.NewFakeClock(time.Date(2026, 3, 7, 0, 0, 0, 0, time.UTC))
+	pushes := &pushRecorder{clock: clock}
+	b := newTestLineBot(clock, newMemoryStore(clock), pushes)
+	return b.AdminHandler(testAdminToken), pushes
+}
+
+func TestAdminAuth(t *testing.T) {
+	h, _ := newTestAdminHandler()
+	tests := []struct {
+		name string
+		auth string
+	}{
+		{"missing token", ""},
+		{"wrong token", "Bearer wrong"},
+		{"token prefix", "Bearer secre"},
+		{"other scheme", "Basic " + testAdminToken},
+		{"no scheme", testAdminToken},
+	}
+	for _, test := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/admin/timers", nil)
+		if test.auth != "" {
+			req.Header.Set("Authorization", test.auth)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("%s: got status %d, want %d", test.name, w.Code, http.StatusUnauthorized)
+		}
+		if w.Header().Get("WWW-Authenticate") != "Bearer" {
+			t.Errorf("%s: got WWW-Authenticate %q, want Bearer", test.name, w.Header().Get("WWW-Authenticate"))
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/timers", nil)
+	req.Header.Set("Authorization", "Bearer "+testAdminToken)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("Got status %d with the right token, want %d", w.Code, http.StatusOK)
+	}
+}
+
+func TestAdminAuthWithoutToken(t *testing.T) {
+	clock := util.NewFakeClock(time.Date(2026, 3, 7, 0, 0, 0, 0, time.UTC))
+	h := newTestLineBot(clock, newMemoryStore(clock), &pushRecorder{clock: clock}).AdminHandler("")
+	for _, auth := range []string{"", "Bearer ", "Bearer"} {
+		req := httptest.NewRequest(http.MethodGet, "/admin/timers", nil)
+		req.Header.Set("Authorization", auth)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("Got status %d with no admin token and authorization %q, want %d", w.Code, auth, http.StatusUnauthorized)
+		}
+	}
+}
+
+func TestAdminRouting(t *testing.T) {
+	h, pushes := newTestAdminHandler()
+	tests := []struct {
+		method string
+		path   string
+		body   string
+		status int
+	}{
+		{http.MethodGet, "/admin/timers", "", http.StatusOK},
+		{http.MethodPost, "/admin/timers", "", http.StatusMethodNotAllowed},
+		{http.MethodPost, "/admin/chats", "", http.StatusMethodNotAllowed},
+		{http.MethodGet, "/admin/quota", "", http.StatusOK},
+		{http.MethodDelete, "/admin/quota", "", http.StatusMethodNotAllowed},
+		{http.MethodGet, "/admin/unknown", "", http.StatusNotFound},
+		{http.MethodGet, "/admin/chats/nobody", "", http.StatusNotFound},
+		{http.MethodGet, "/admin/chats/user:U1/unknown", "", http.StatusNotFound},
+		{http.MethodDelete, "/admin/chats/user:U1", "", http.StatusMethodNotAllowed},
+		{http.MethodGet, "/admin/chats/user:U1/push", "", http.StatusMethodNotAllowed},
+		{http.MethodPut, "/admin/chats/user:U1", "{", http.StatusBadRequest},
+		{http.MethodPut, "/admin/chats/user:U1", `{"tz":"Mars/Olympus"}`, http.StatusBadRequest},
+		{http.MethodPost, "/admin/chats/user:U1/push", "{", http.StatusBadRequest},
+		{http.MethodPost, "/admin/chats/user:U1/push", `{"text":"Hello"}`, http.StatusOK},
+	}
+	for _, test := range tests {
+		req := httptest.NewRequest(test.method, test.path, strings.NewReader(test.body))
+		req.Header.Set("Authorization", "Bearer "+testAdminToken)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		if w.Code != test.status {
+			t.Errorf("%s %s: got status %d, want %d: %s", test.method, test.path, w.Code, test.status, w.Body.String())
+		}
+	}
+
+	got := pushes.recorded()
+	if len(got) != 1 || got[0].To != "U1" || got[0].Text != "Hello" {
+		t.Errorf("Got pushes %+v, want a single push of Hello to U1", got)
+	}
+}
+
+func TestAdminPushSplitsLongText(t *testing.T) {
+	h, pushes := newTestAdminHandler()
+	line := strings.Repeat("a", 600)
+	text := line + `\n` + line + `\n` + strings.Repeat("b", 2500)
+	req := httptest.NewRequest(http.MethodPost, "/admin/chats/group:C1/push", strings.NewReader(`{"text":"`+text+`"}`))
+	req.Header.Set("Authorization", "Bearer "+testAdminToken)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Got status %d, want %d: %s", w.Code, http.StatusOK, w.Body.String())
+	}
+	if body := strings.TrimSpace(w.Body.String()); body != `{"pushed":5}` {
+		t.Errorf("Got response %s, want 5 messages pushed", body)
+	}
+
+	got := pushes.recorded()
+	if len(got) != 1 || got[0].Messages != 5 {
+		t.Fatalf("Got pushes %+v, want a single push of 5 messages", got)
+	}
+	// The recorder joins the texts of the messages with newlines
+	if got[0].Text != line+"\n"+line+"\n"+strings.Repeat("b", 1000)+"\n"+strings.Repeat("b", 1000)+"\n"+strings.Repeat("b", 500) {
+		t.Errorf("Got text %q, want the text split into messages of at most 1000 bytes", got[0].Text)
+	}
+}
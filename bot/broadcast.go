@@ -0,0 +1,179 @@
+package bot
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/azaky/cpbot/logging"
+	"github.com/azaky/cpbot/util"
+	"github.com/line/line-bot-sdk-go/linebot"
+)
+
+// Line limits how fast messages can be pushed, so broadcasts push to one
+// chat at a time, this long apart by default.
+const lineBroadcastInterval = 100 * time.Millisecond
+
+// BroadcastOptions controls which chats a broadcast goes to, and how.
+type BroadcastOptions struct {
+	// Types of chats to broadcast to. Empty means every type.
+	Types []linebot.EventSourceType
+	// Interval between pushes. Defaults to lineBroadcastInterval.
+	Interval time.Duration
+	// DryRun lists the chats a broadcast would go to, without pushing.
+	DryRun bool
+}
+
+// BroadcastProgress reports the result of broadcasting to a single chat.
+type BroadcastProgress struct {
+	Chat  string `json:"chat"`
+	Done  int    `json:"done"`
+	Total int    `json:"total"`
+	Error string `json:"error,omitempty"`
+}
+
+// BroadcastResult summarizes a broadcast.
+type BroadcastResult struct {
+	Total  int               `json:"total"`
+	Sent   int               `json:"sent"`
+	Failed map[string]string `json:"failed,omitempty"`
+	DryRun bool              `json:"dry_run,omitempty"`
+}
+
+// Broadcast pushes messages to every chat matching opts, one at a time.
+// progress, if not nil, is called after each chat.
+func (b *LineBot) Broadcast(messages []string, opts BroadcastOptions, progress func(BroadcastProgress)) (BroadcastResult, error) {
+	result := BroadcastResult{DryRun: opts.DryRun}
+	users, err := b.users.GetUsers()
+	if err != nil {
+		return result, err
+	}
+	var chats []*linebot.EventSource
+	for _, user := range users {
+		eventSource, err := util.StringToLineEventSource(user)
+		if err != nil {
+			logging.WithChat(broadcastLog, user).WithError(err).Warn("Found invalid user")
+			continue
+		}
+		if broadcastTypeMatches(eventSource.Type, opts.Types) {
+			chats = append(chats, eventSource)
+		}
+	}
+	result.Total = len(chats)
+	broadcastLog.Infof("Broadcasting %d messages to %d chats (dry run: %t)", len(messages), len(chats), opts.DryRun)
+
+	interval := opts.Interval
+	if interval <= 0 {
+		interval = lineBroadcastInterval
+	}
+	ticker := b.clock.NewTicker(interval)
+	defer ticker.Stop()
+
+	for i, chat := range chats {
+		if i > 0 && !opts.DryRun {
+			<-ticker.C()
+		}
+		p := BroadcastProgress{Chat: util.LineEventSourceToString(chat), Done: i + 1, Total: len(chats)}
+		if !opts.DryRun {
+			// Broadcasts are by admins, so they are sent while there is quota
+			if err := b.push(chat, pushHigh, messages...); err != nil {
+				p.Error = err.Error()
+				if result.Failed == nil {
+					result.Failed = make(map[string]string)
+				}
+				result.Failed[p.Chat] = p.Error
+			}
+		}
+		if p.Error == "" {
+			result.Sent++
+		}
+		if progress != nil {
+			progress(p)
+		}
+	}
+	broadcastLog.Infof("Done: %d sent, %d failed", result.Sent, len(result.Failed))
+	return result, nil
+}
+
+func broadcastTypeMatches(t linebot.EventSourceType, types []linebot.EventSourceType) bool {
+	if len(types) == 0 {
+		return true
+	}
+	for _, u := range types {
+		if t == u {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseBroadcastTypes parses a comma separated list of chat types, such as
+// "user,group".
+func ParseBroadcastTypes(s string) ([]linebot.EventSourceType, error) {
+	var types []linebot.EventSourceType
+	for _, t := range strings.Split(s, ",") {
+		t = strings.ToLower(strings.TrimSpace(t))
+		switch linebot.EventSourceType(t) {
+		case "":
+			continue
+		case linebot.EventSourceTypeUser, linebot.EventSourceTypeGroup, linebot.EventSourceTypeRoom:
+			types = append(types, linebot.EventSourceType(t))
+		default:
+			return nil, fmt.Errorf("Invalid chat type: %s", t)
+		}
+	}
+	return types, nil
+}
+
+type adminBroadcastRequest struct {
+	Text       string `json:"text"`
+	Types      string `json:"types"`
+	IntervalMS int    `json:"interval_ms"`
+	DryRun     bool   `json:"dry_run"`
+}
+
+// adminBroadcast broadcasts a message, streaming the progress of each chat
+// as a line of JSON, followed by the result.
+func (b *LineBot) adminBroadcast(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		writeAdminError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	var body adminBroadcastRequest
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		writeAdminError(w, http.StatusBadRequest, fmt.Sprintf("invalid body: %s", err.Error()))
+		return
+	}
+	if body.Text == "" {
+		writeAdminError(w, http.StatusBadRequest, "text is required")
+		return
+	}
+	types, err := ParseBroadcastTypes(body.Types)
+	if err != nil {
+		writeAdminError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	opts := BroadcastOptions{
+		Types:    types,
+		Interval: time.Duration(body.IntervalMS) * time.Millisecond,
+		DryRun:   body.DryRun,
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+	encoder := json.NewEncoder(w)
+	flusher, _ := w.(http.Flusher)
+	result, err := b.Broadcast([]string{body.Text}, opts, func(p BroadcastProgress) {
+		encoder.Encode(p)
+		if flusher != nil {
+			flusher.Flush()
+		}
+	})
+	if err != nil {
+		encoder.Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	encoder.Encode(map[string]interface{}{"result": result})
+}
@@ -0,0 +1,99 @@
+package bot
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/azaky/cpbot/util"
+	"github.com/line/line-bot-sdk-go/linebot"
+)
+
+func newTestBroadcastBot() (*LineBot, *pushRecorder) {
+	clock := util.NewFakeClock(time.Date(2026, 3, 7, 0, 0, 0, 0, time.UTC))
+	store := newMemoryStore(clock)
+	store.users = []string{"user:U1", "group:C1", "room:R1", "nobody"}
+	pushes := &pushRecorder{clock: clock}
+	return newTestLineBot(clock, store, pushes), pushes
+}
+
+func broadcastChats(b *LineBot, opts BroadcastOptions) ([]string, BroadcastResult, error) {
+	var chats []string
+	result, err := b.Broadcast([]string{"Hello"}, opts, func(p BroadcastProgress) {
+		chats = append(chats, p.Chat)
+	})
+	return chats, result, err
+}
+
+func TestBroadcastDryRun(t *testing.T) {
+	b, pushes := newTestBroadcastBot()
+	chats, result, err := broadcastChats(b, BroadcastOptions{DryRun: true})
+	if err != nil {
+		t.Fatalf("Broadcast: %s", err)
+	}
+	// Invalid users are skipped
+	if want := []string{"user:U1", "group:C1", "room:R1"}; !reflect.DeepEqual(chats, want) {
+		t.Errorf("Got progress of %v, want %v", chats, want)
+	}
+	if want := (BroadcastResult{Total: 3, Sent: 3, DryRun: true}); !reflect.DeepEqual(result, want) {
+		t.Errorf("Got result %+v, want %+v", result, want)
+	}
+	if got := pushes.recorded(); len(got) != 0 {
+		t.Errorf("Got pushes %+v on a dry run, want none", got)
+	}
+}
+
+func TestBroadcastTypes(t *testing.T) {
+	b, _ := newTestBroadcastBot()
+	tests := []struct {
+		types []linebot.EventSourceType
+		want  []string
+	}{
+		{nil, []string{"user:U1", "group:C1", "room:R1"}},
+		{[]linebot.EventSourceType{linebot.EventSourceTypeUser}, []string{"user:U1"}},
+		{[]linebot.EventSourceType{linebot.EventSourceTypeGroup, linebot.EventSourceTypeRoom}, []string{"group:C1", "room:R1"}},
+	}
+	for _, test := range tests {
+		chats, result, err := broadcastChats(b, BroadcastOptions{Types: test.types, DryRun: true})
+		if err != nil {
+			t.Fatalf("Broadcast to %v: %s", test.types, err)
+		}
+		if !reflect.DeepEqual(chats, test.want) || result.Total != len(test.want) {
+			t.Errorf("Broadcast to %v went to %v of %d chats, want %v", test.types, chats, result.Total, test.want)
+		}
+	}
+}
+
+func TestBroadcastPushesToMatchingChats(t *testing.T) {
+	b, pushes := newTestBroadcastBot()
+	_, result, err := broadcastChats(b, BroadcastOptions{Types: []linebot.EventSourceType{linebot.EventSourceTypeGroup}})
+	if err != nil {
+		t.Fatalf("Broadcast: %s", err)
+	}
+	if want := (BroadcastResult{Total: 1, Sent: 1}); !reflect.DeepEqual(result, want) {
+		t.Errorf("Got result %+v, want %+v", result, want)
+	}
+	got := pushes.recorded()
+	if len(got) != 1 || got[0].To != "C1" || got[0].Text != "Hello" {
+		t.Errorf("Got pushes %+v, want a single push of Hello to C1", got)
+	}
+}
+
+func TestParseBroadcastTypes(t *testing.T) {
+	tests := []struct {
+		s    string
+		want []linebot.EventSourceType
+		ok   bool
+	}{
+		{"", nil, true},
+		{"user", []linebot.EventSourceType{linebot.EventSourceTypeUser}, true},
+		{" Group , room,", []linebot.EventSourceType{linebot.EventSourceTypeGroup, linebot.EventSourceTypeRoom}, true},
+		{"user,channel", nil, false},
+	}
+	for _, test := range tests {
+		got, err := ParseBroadcastTypes(test.s)
+		if (err == nil) != test.ok || !reflect.DeepEqual(got, test.want) {
+			t.Errorf("ParseBroadcastTypes(%q) = %v, %v, want %v, ok %t", test.s, got, err, test.want, test.ok)
+		}
+	}
+}
@@ -2,44 +2,232 @@ package bot
 
 import (
 	"bytes"
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
-	"log"
+	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/azaky/cpbot/clist"
+	"github.com/azaky/cpbot/util"
+	"github.com/sirupsen/logrus"
 )
 
 func generateUpcomingContestsMessage(clistService *clist.Service, startFrom, startTo time.Time, tz *time.Location, message string, limit int) ([]string, error) {
 	contests, err := clistService.GetContestsStartingBetween(startFrom, startTo)
 	if err != nil {
-		log.Printf("Error generate24HUpcomingContestsMessage: %s", err.Error())
+		logrus.WithError(err).Error("Error getting upcoming contests")
 		return nil, err
 	}
 
+	return formatUpcomingContestsMessage(contests, tz, message, limit), nil
+}
+
+func formatUpcomingContestsMessage(contests []clist.Contest, tz *time.Location, message string, limit int) []string {
+	lines := []string{message, ""}
+	for _, contest := range contests {
+		lines = append(lines, fmt.Sprintf("- %s. Starts at %s. Link: %s", contest.Name, contest.StartDate.In(tz).Format("Jan 2 15:04 MST"), contest.Link))
+	}
+	if len(contests) == 0 {
+		lines[1] = "0 contest found"
+	}
+	return splitMessage(lines, limit)
+}
+
+// splitMessage joins lines into as few messages of at most limit bytes as
+// possible, breaking them only between lines. Lines longer than limit are
+// broken up on their own.
+func splitMessage(lines []string, limit int) []string {
 	var buffer bytes.Buffer
-	buffer.WriteString(message)
-	buffer.WriteString("\n")
 	var res []string
-	for _, contest := range contests {
-		str := fmt.Sprintf("- %s. Starts at %s. Link: %s", contest.Name, contest.StartDate.In(tz).Format("Jan 2 15:04 MST"), contest.Link)
-		if buffer.Len()+len(str)+1 > limit {
+	for i, line := range lines {
+		if i == 0 {
+			buffer.WriteString(line)
+		} else if buffer.Len()+len(line)+1 > limit {
 			res = append(res, buffer.String())
-			buffer = *bytes.NewBufferString(str)
+			buffer.Reset()
+			buffer.WriteString(line)
 		} else {
 			buffer.WriteString("\n")
-			buffer.WriteString(str)
+			buffer.WriteString(line)
+		}
+		for buffer.Len() > limit {
+			head := buffer.String()
+			cut := limit
+			for cut > 0 && !utf8.RuneStart(head[cut]) {
+				cut--
+			}
+			if cut == 0 {
+				// limit is shorter than a single character
+				cut = limit
+			}
+			res = append(res, head[:cut])
+			buffer.Reset()
+			buffer.WriteString(head[cut:])
 		}
 	}
-	if len(contests) == 0 {
-		buffer.WriteString("0 contest found")
+	return append(res, buffer.String())
+}
+
+func generate24HUpcomingContestsMessage(clistService *clist.Service, now time.Time, tz *time.Location, limit int) ([]string, error) {
+	startFrom := now
+	startTo := now.Add(86400 * time.Second)
+	return generateUpcomingContestsMessage(clistService, startFrom, startTo, tz, "Contests in the next 24 hours:", limit)
+}
+
+const (
+	dailyWindowDefault = 86400 * time.Second
+	dailyWindowToday   = "today"
+)
+
+// parseDailyWindow validates a daily window, which is either "today" (until
+// midnight in the chat's timezone) or a positive duration of at most max,
+// such as "48h".
+func parseDailyWindow(window string, max time.Duration) (string, error) {
+	window = strings.ToLower(window)
+	if window == dailyWindowToday {
+		return window, nil
+	}
+	duration, err := time.ParseDuration(window)
+	if err != nil {
+		return "", err
+	}
+	if duration <= 0 {
+		return "", fmt.Errorf("Invalid window: duration must be positive")
+	}
+	if duration > max {
+		return "", fmt.Errorf("Invalid window: duration must be at most %s", formatDuration(max))
 	}
-	res = append(res, buffer.String())
+	return window, nil
+}
 
-	return res, nil
+// formatDuration formats d without trailing zero units, e.g. 720h rather
+// than 720h0m0s.
+func formatDuration(d time.Duration) string {
+	s := d.String()
+	if strings.HasSuffix(s, "m0s") {
+		s = strings.TrimSuffix(s, "0s")
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = strings.TrimSuffix(s, "0m")
+	}
+	return s
 }
 
-func generate24HUpcomingContestsMessage(clistService *clist.Service, tz *time.Location, limit int) ([]string, error) {
-	startFrom := time.Now()
-	startTo := time.Now().Add(86400 * time.Second)
-	return generateUpcomingContestsMessage(clistService, startFrom, startTo, tz, "Contests in the next 24 hours:", limit)
+func describeDailyWindow(window string) string {
+	if window == dailyWindowToday {
+		return "the rest of today"
+	}
+	duration, err := time.ParseDuration(window)
+	if err != nil || duration <= 0 {
+		duration = dailyWindowDefault
+	}
+	if duration == time.Hour {
+		return "the next hour"
+	}
+	if duration%time.Hour == 0 {
+		return fmt.Sprintf("the next %d hours", duration/time.Hour)
+	}
+	return fmt.Sprintf("the next %s", window)
+}
+
+// dailyWindowRange returns the range of contest start times covered by a
+// daily reminder sent at now, along with the header of the message. Windows
+// longer than max, which might have been set before there was a limit, are
+// cut to max.
+func dailyWindowRange(window string, max time.Duration, now time.Time, tz *time.Location) (time.Time, time.Time, string) {
+	if window == dailyWindowToday {
+		local := now.In(tz)
+		midnight := time.Date(local.Year(), local.Month(), local.Day()+1, 0, 0, 0, 0, tz)
+		return now, midnight, "Contests until midnight today:"
+	}
+	duration, err := time.ParseDuration(window)
+	if err != nil || duration <= 0 {
+		duration = dailyWindowDefault
+	}
+	if duration > max {
+		duration = max
+		window = formatDuration(max)
+	}
+	return now, now.Add(duration), fmt.Sprintf("Contests in %s:", describeDailyWindow(window))
+}
+
+// exportedSettings are the settings of a chat, as exported to be imported
+// into another chat, possibly on another deployment.
+type exportedSettings struct {
+	Timezone       string   `json:"tz,omitempty"`
+	Daily          []string `json:"daily,omitempty"`
+	DailyWindow    string   `json:"window,omitempty"`
+	DailySkipEmpty *bool    `json:"skip_empty,omitempty"`
+	Quiet          string   `json:"quiet,omitempty"`
+}
+
+// settingsCodePrefix marks (and versions) settings encoded by
+// encodeSettings.
+const settingsCodePrefix = "cpbot1."
+
+// encodeSettings encodes settings as a compact code that is safe to be sent
+// as a single word in a chat.
+func encodeSettings(settings exportedSettings) (string, error) {
+	blob, err := json.Marshal(settings)
+	if err != nil {
+		return "", err
+	}
+	return settingsCodePrefix + base64.RawURLEncoding.EncodeToString(blob), nil
+}
+
+// decodeSettings decodes settings encoded by encodeSettings, or given as a
+// plain JSON blob, and validates them against maxWindow.
+func decodeSettings(code string, maxWindow time.Duration) (exportedSettings, error) {
+	var settings exportedSettings
+	code = strings.TrimSpace(code)
+	blob := []byte(code)
+	if strings.HasPrefix(code, settingsCodePrefix) {
+		var err error
+		blob, err = base64.RawURLEncoding.DecodeString(strings.TrimPrefix(code, settingsCodePrefix))
+		if err != nil {
+			return settings, fmt.Errorf("Invalid settings code")
+		}
+	} else if !strings.HasPrefix(code, "{") {
+		return settings, fmt.Errorf("Invalid settings code")
+	}
+	if err := json.Unmarshal(blob, &settings); err != nil {
+		return settings, fmt.Errorf("Invalid settings code")
+	}
+	return settings, settings.validate(maxWindow)
+}
+
+// validate checks settings, with daily windows of at most maxWindow, and
+// normalizes them to the form they are stored in.
+func (s *exportedSettings) validate(maxWindow time.Duration) error {
+	if s.Timezone != "" {
+		loc, err := util.LoadLocation(s.Timezone)
+		if err != nil {
+			return fmt.Errorf("%s is not a valid timezone", s.Timezone)
+		}
+		s.Timezone = loc.String()
+	}
+	for i, daily := range s.Daily {
+		t, err := util.ParseTime(daily)
+		if err != nil {
+			return fmt.Errorf("%s is not a valid daily reminder time", daily)
+		}
+		s.Daily[i] = util.FormatTimeOfDay(t)
+	}
+	if s.DailyWindow != "" {
+		window, err := parseDailyWindow(s.DailyWindow, maxWindow)
+		if err != nil {
+			return fmt.Errorf("%s is not a valid daily window", s.DailyWindow)
+		}
+		s.DailyWindow = window
+	}
+	if s.Quiet != "" {
+		from, to, err := util.ParseTimeRange(s.Quiet)
+		if err != nil || from == to {
+			return fmt.Errorf("%s is not a valid time range", s.Quiet)
+		}
+		s.Quiet = fmt.Sprintf("%s-%s", util.FormatTimeOfDay(from), util.FormatTimeOfDay(to))
+	}
+	return nil
 }
@@ -0,0 +1,188 @@
+package bot
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testMaxWindow = 30 * 24 * time.Hour
+
+func TestParseDailyWindow(t *testing.T) {
+	tests := []struct {
+		window string
+		want   string
+		ok     bool
+	}{
+		{"today", "today", true},
+		{"TODAY", "today", true},
+		{"48h", "48h", true},
+		{"90m", "90m", true},
+		{"720h", "720h", true},
+		{"721h", "", false},
+		{"87600h", "", false},
+		{"0h", "", false},
+		{"-1h", "", false},
+		{"tomorrow", "", false},
+		{"48", "", false},
+	}
+	for _, test := range tests {
+		got, err := parseDailyWindow(test.window, testMaxWindow)
+		if (err == nil) != test.ok || got != test.want {
+			t.Errorf("parseDailyWindow(%q) = %q, %v, want %q, ok %t", test.window, got, err, test.want, test.ok)
+		}
+	}
+}
+
+func TestDescribeDailyWindow(t *testing.T) {
+	tests := []struct {
+		window string
+		want   string
+	}{
+		{"", "the next 24 hours"},
+		{"today", "the rest of today"},
+		{"1h", "the next hour"},
+		{"48h", "the next 48 hours"},
+		{"90m", "the next 90m"},
+	}
+	for _, test := range tests {
+		if got := describeDailyWindow(test.window); got != test.want {
+			t.Errorf("describeDailyWindow(%q) = %q, want %q", test.window, got, test.want)
+		}
+	}
+}
+
+func TestDailyWindowRange(t *testing.T) {
+	jakarta, err := time.LoadLocation("Asia/Jakarta")
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2026, 3, 7, 20, 0, 0, 0, time.UTC)
+	tests := []struct {
+		window string
+		to     time.Time
+		header string
+	}{
+		{"", now.Add(24 * time.Hour), "Contests in the next 24 hours:"},
+		{"48h", now.Add(48 * time.Hour), "Contests in the next 48 hours:"},
+		// 03:00 on March 8 in Jakarta, so until midnight of March 8
+		{"today", time.Date(2026, 3, 8, 17, 0, 0, 0, time.UTC), "Contests until midnight today:"},
+		// Set before there was a limit
+		{"87600h", now.Add(testMaxWindow), "Contests in the next 720 hours:"},
+	}
+	for _, test := range tests {
+		from, to, header := dailyWindowRange(test.window, testMaxWindow, now, jakarta)
+		if !from.Equal(now) || !to.Equal(test.to) || header != test.header {
+			t.Errorf("dailyWindowRange(%q) = %s, %s, %q, want %s, %s, %q", test.window, from, to, header, now, test.to, test.header)
+		}
+	}
+}
+
+func TestEncodeDecodeSettings(t *testing.T) {
+	skip := true
+	settings := exportedSettings{
+		Timezone:       "Asia/Jakarta",
+		Daily:          []string{"09:00", "21:30"},
+		DailyWindow:    "48h",
+		DailySkipEmpty: &skip,
+		Quiet:          "22:00-06:00",
+	}
+	code, err := encodeSettings(settings)
+	if err != nil {
+		t.Fatalf("encodeSettings: %s", err)
+	}
+	if !strings.HasPrefix(code, settingsCodePrefix) || strings.ContainsAny(code, " \n+/=") {
+		t.Errorf("encodeSettings = %q, want a single word starting with %s", code, settingsCodePrefix)
+	}
+	decoded, err := decodeSettings(code, testMaxWindow)
+	if err != nil {
+		t.Fatalf("decodeSettings(%q): %s", code, err)
+	}
+	if !reflect.DeepEqual(decoded, settings) {
+		t.Errorf("decodeSettings(encodeSettings(%+v)) = %+v", settings, decoded)
+	}
+}
+
+func TestDecodeSettings(t *testing.T) {
+	tests := []struct {
+		code string
+		want exportedSettings
+		ok   bool
+	}{
+		{"{}", exportedSettings{}, true},
+		{`  {"tz":"utc+7","daily":["9"]}  `, exportedSettings{Timezone: "UTC+7", Daily: []string{"09:00"}}, true},
+		{settingsCodePrefix + "e30", exportedSettings{}, true},
+		{"", exportedSettings{}, false},
+		{"cpbot0.e30", exportedSettings{}, false},
+		{settingsCodePrefix + "!!!", exportedSettings{}, false},
+		{`{"tz":`, exportedSettings{}, false},
+		{`{"tz":"Mars/Olympus"}`, exportedSettings{}, false},
+	}
+	for _, test := range tests {
+		got, err := decodeSettings(test.code, testMaxWindow)
+		if (err == nil) != test.ok {
+			t.Errorf("decodeSettings(%q) error = %v, want ok %t", test.code, err, test.ok)
+			continue
+		}
+		if test.ok && !reflect.DeepEqual(got, test.want) {
+			t.Errorf("decodeSettings(%q) = %+v, want %+v", test.code, got, test.want)
+		}
+	}
+}
+
+func TestValidateSettings(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings exportedSettings
+		want     exportedSettings
+		ok       bool
+	}{
+		{"empty", exportedSettings{}, exportedSettings{}, true},
+		{"normalized",
+			exportedSettings{Timezone: "asia/jakarta", Daily: []string{"9", "21:30:00"}, DailyWindow: "TODAY", Quiet: "22 - 6"},
+			exportedSettings{Timezone: "Asia/Jakarta", Daily: []string{"09:00", "21:30"}, DailyWindow: "today", Quiet: "22:00-06:00"},
+			true},
+		{"invalid timezone", exportedSettings{Timezone: "Mars/Olympus"}, exportedSettings{}, false},
+		{"invalid daily time", exportedSettings{Daily: []string{"09:00", "25:00"}}, exportedSettings{}, false},
+		{"invalid daily window", exportedSettings{DailyWindow: "forever"}, exportedSettings{}, false},
+		{"daily window too long", exportedSettings{DailyWindow: "721h"}, exportedSettings{}, false},
+		{"invalid quiet hours", exportedSettings{Quiet: "22:00"}, exportedSettings{}, false},
+		{"empty quiet hours", exportedSettings{Quiet: "22:00-22:00"}, exportedSettings{}, false},
+	}
+	for _, test := range tests {
+		err := test.settings.validate(testMaxWindow)
+		if (err == nil) != test.ok {
+			t.Errorf("%s: validate() error = %v, want ok %t", test.name, err, test.ok)
+			continue
+		}
+		if test.ok && !reflect.DeepEqual(test.settings, test.want) {
+			t.Errorf("%s: validate() normalized to %+v, want %+v", test.name, test.settings, test.want)
+		}
+	}
+}
+
+func TestSplitMessage(t *testing.T) {
+	tests := []struct {
+		lines []string
+		limit int
+		want  []string
+	}{
+		{[]string{"hello"}, 10, []string{"hello"}},
+		{[]string{""}, 10, []string{""}},
+		{[]string{"ab", "cd", "ef"}, 5, []string{"ab\ncd", "ef"}},
+		{[]string{"ab", "cd", "ef"}, 8, []string{"ab\ncd\nef"}},
+		{[]string{"header", "", "- a", "- b"}, 10, []string{"header\n", "- a\n- b"}},
+		// Lines longer than limit are broken up on their own
+		{[]string{"ab", "cdefghij", "k"}, 3, []string{"ab", "cde", "fgh", "ij", "k"}},
+		{[]string{"cdefgh", "i"}, 4, []string{"cdef", "gh\ni"}},
+		// without breaking characters
+		{[]string{"aé€"}, 4, []string{"aé", "€"}},
+	}
+	for _, test := range tests {
+		got := splitMessage(test.lines, test.limit)
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("splitMessage(%q, %d) = %q, want %q", test.lines, test.limit, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,271 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/azaky/cpbot/util"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+const (
+	testDailyPeriod = 30 * time.Minute
+	testDailyGrace  = time.Hour
+)
+
+func mustLoadLocation(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Fatalf("Error loading %s: %v", name, err)
+	}
+	return loc
+}
+
+// startDailyJobs starts the daily job of every bot, which runs right away.
+func startDailyJobs(bots []*LineBot) {
+	for _, b := range bots {
+		b.StartDailyJob(testDailyPeriod, testDailyGrace)
+	}
+}
+
+func stopDailyJobs(bots []*LineBot) {
+	for _, b := range bots {
+		b.StopDailyJob()
+	}
+}
+
+// advance moves clock forward by periods of the daily job, waiting for the
+// job of every bot to finish after each one. Reminders are delivered by
+// clock.Advance itself, as FakeClock runs timers synchronously.
+func advance(t *testing.T, clock *util.FakeClock, bots []*LineBot, periods int) {
+	t.Helper()
+	for i := 0; i < periods; i++ {
+		clock.Advance(testDailyPeriod)
+		now := clock.Now()
+		deadline := time.Now().Add(5 * time.Second)
+		for _, b := range bots {
+			for {
+				if _, last := b.scheduler.alive(); last.Equal(now) {
+					break
+				}
+				if time.Now().After(deadline) {
+					t.Fatalf("Daily job did not run at %s", now)
+				}
+				time.Sleep(time.Millisecond)
+			}
+			b.scheduler.wait(context.Background())
+		}
+	}
+}
+
+func TestDailyDeliveredOnceByManyInstances(t *testing.T) {
+	start := time.Date(2026, 3, 7, 0, 0, 0, 0, time.UTC)
+	clock := util.NewFakeClock(start)
+	store := newMemoryStore(clock)
+	pushes := &pushRecorder{clock: clock}
+
+	type reminder struct {
+		user string
+		t    int
+		tz   *time.Location
+	}
+	reminders := []reminder{
+		{"user:U1", 9 * 3600, time.UTC},
+		{"user:U1", 21*3600 + 30*60, time.UTC},
+		{"user:U2", 2*3600 + 30*60, mustLoadLocation(t, "America/New_York")},
+		{"group:C3", 0, mustLoadLocation(t, "Asia/Jakarta")},
+		{"room:R4", 23*3600 + 59*60, mustLoadLocation(t, "Europe/London")},
+	}
+	for _, r := range reminders {
+		store.addDaily(r.user, r.t, r.tz)
+	}
+
+	var bots []*LineBot
+	for i := 0; i < 3; i++ {
+		bots = append(bots, newTestLineBot(clock, store, pushes))
+	}
+	startDailyJobs(bots)
+	// Three days, across the start of daylight saving time in New York
+	advance(t, clock, bots, 3*48)
+	stopDailyJobs(bots)
+	end := clock.Now()
+
+	var want []string
+	for _, r := range reminders {
+		chat, _ := util.StringToLineEventSource(r.user)
+		to := util.LineEventSourceToReplyString(chat)
+		for occurrence := util.NextTime(start, r.t, r.tz); occurrence.Before(end); occurrence = util.NextTime(occurrence.Add(time.Second), r.t, r.tz) {
+			want = append(want, to+"@"+occurrence.UTC().Format(time.RFC3339))
+		}
+	}
+	var got []string
+	for _, p := range pushes.recorded() {
+		got = append(got, p.To+"@"+p.At.UTC().Format(time.RFC3339))
+	}
+	sort.Strings(want)
+	sort.Strings(got)
+	if len(got) != len(want) {
+		t.Fatalf("Got %d pushes, want %d:\ngot  %v\nwant %v", len(got), len(want), got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("Got pushes %v, want %v", got, want)
+		}
+	}
+}
+
+func TestDailyRetriedAfterFailedPush(t *testing.T) {
+	start := time.Date(2026, 3, 7, 0, 0, 0, 0, time.UTC)
+	clock := util.NewFakeClock(start)
+	store := newMemoryStore(clock)
+	pushes := &pushRecorder{clock: clock}
+	failures := 1
+	pushes.fail = func(to string) error {
+		if failures > 0 {
+			failures--
+			return errors.New("Line is down")
+		}
+		return nil
+	}
+	store.addDaily("user:U1", 10*60, time.UTC)
+
+	bots := []*LineBot{newTestLineBot(clock, store, pushes)}
+	startDailyJobs(bots)
+	advance(t, clock, bots, 2)
+	stopDailyJobs(bots)
+
+	got := pushes.recorded()
+	if pushes.failed != 1 || len(got) != 1 {
+		t.Fatalf("Got %d failed and %d successful pushes, want 1 and 1", pushes.failed, len(got))
+	}
+	// Retried by the next run of the daily job
+	if want := start.Add(testDailyPeriod); !got[0].At.Equal(want) {
+		t.Errorf("Pushed at %s, want %s", got[0].At, want)
+	}
+}
+
+func TestDailyDeliveredAfterClaimExpires(t *testing.T) {
+	start := time.Date(2026, 3, 7, 0, 0, 0, 0, time.UTC)
+	clock := util.NewFakeClock(start)
+	store := newMemoryStore(clock)
+	pushes := &pushRecorder{clock: clock}
+	store.addDaily("user:U1", 10*60, time.UTC)
+
+	// An instance claims the occurrence, and dies before delivering it
+	occurrence := start.Add(10 * time.Minute)
+	clock.AdvanceTo(occurrence)
+	if ok, _ := store.ClaimDaily("user:U1", 10*60, occurrence, lineDailyClaimTTL); !ok {
+		t.Fatal("Could not claim occurrence")
+	}
+
+	bots := []*LineBot{newTestLineBot(clock, store, pushes)}
+	startDailyJobs(bots)
+	if got := pushes.recorded(); len(got) != 0 {
+		t.Fatalf("Pushed %v while the occurrence is claimed", got)
+	}
+	// The job runs every 30 minutes from 00:10, the run at 00:40 catches up
+	advance(t, clock, bots, 2)
+	stopDailyJobs(bots)
+
+	got := pushes.recorded()
+	if len(got) != 1 {
+		t.Fatalf("Got %d pushes, want 1", len(got))
+	}
+	if want := occurrence.Add(testDailyPeriod); !got[0].At.Equal(want) {
+		t.Errorf("Pushed at %s, want %s", got[0].At, want)
+	}
+}
+
+func TestDailyDeferredInQuietHours(t *testing.T) {
+	start := time.Date(2026, 3, 7, 0, 0, 0, 0, time.UTC)
+	clock := util.NewFakeClock(start)
+	store := newMemoryStore(clock)
+	pushes := &pushRecorder{clock: clock}
+	store.addDaily("user:U1", 3600, time.UTC)
+	store.setQuiet("user:U1", "00:00-02:00")
+	delivered := testutil.ToFloat64(dailyRemindersDeliveredTotal)
+
+	bots := []*LineBot{newTestLineBot(clock, store, pushes), newTestLineBot(clock, store, pushes)}
+	startDailyJobs(bots)
+	advance(t, clock, bots, 3)
+	if got := testutil.ToFloat64(dailyRemindersDeliveredTotal) - delivered; got != 0 {
+		t.Errorf("Counted %v deferred reminders as delivered", got)
+	}
+	advance(t, clock, bots, 3)
+	stopDailyJobs(bots)
+
+	if got := testutil.ToFloat64(dailyRemindersDeliveredTotal) - delivered; got != 1 {
+		t.Errorf("Counted %v reminders as delivered, want 1", got)
+	}
+	got := pushes.recorded()
+	if len(got) != 1 {
+		t.Fatalf("Got %d pushes, want 1", len(got))
+	}
+	if want := start.Add(2 * time.Hour); !got[0].At.Equal(want) {
+		t.Errorf("Pushed at %s, want %s", got[0].At, want)
+	}
+	// Generated when quiet hours end, not when the reminder was due
+	if want := "Test Round from 2026-03-07T02:00:00"; !strings.Contains(got[0].Text, want) {
+		t.Errorf("Pushed %q, want contests from the end of quiet hours", got[0].Text)
+	}
+}
+
+func TestDailyDeferredRetriedAfterFailedPush(t *testing.T) {
+	start := time.Date(2026, 3, 7, 0, 0, 0, 0, time.UTC)
+	clock := util.NewFakeClock(start)
+	store := newMemoryStore(clock)
+	pushes := &pushRecorder{clock: clock}
+	failures := 1
+	pushes.fail = func(to string) error {
+		if failures > 0 {
+			failures--
+			return errors.New("Line is down")
+		}
+		return nil
+	}
+	store.addDaily("user:U1", 3600, time.UTC)
+	store.setQuiet("user:U1", "00:00-02:00")
+
+	bots := []*LineBot{newTestLineBot(clock, store, pushes)}
+	startDailyJobs(bots)
+	advance(t, clock, bots, 6)
+	stopDailyJobs(bots)
+
+	got := pushes.recorded()
+	if pushes.failed != 1 || len(got) != 1 {
+		t.Fatalf("Got %d failed and %d successful pushes, want 1 and 1", pushes.failed, len(got))
+	}
+	// Retried by the next run of the daily job
+	if want := start.Add(2*time.Hour + testDailyPeriod); !got[0].At.Equal(want) {
+		t.Errorf("Pushed at %s, want %s", got[0].At, want)
+	}
+}
+
+func TestDailyJobKeepsTimersWhenRepositoryFails(t *testing.T) {
+	start := time.Date(2026, 3, 7, 0, 0, 0, 0, time.UTC)
+	clock := util.NewFakeClock(start)
+	store := newMemoryStore(clock)
+	pushes := &pushRecorder{clock: clock}
+	store.addDaily("user:U1", 20*60, time.UTC)
+
+	b := newTestLineBot(clock, store, pushes)
+	startDailyJobs([]*LineBot{b})
+	defer b.StopDailyJob()
+
+	store.setError(errors.New("Redis is down"))
+	b.dailyJob(clock.Now())
+	timers, _ := b.scheduler.pending()
+	if len(timers) != 1 {
+		t.Fatalf("Got pending timers %v, want the reminder at 00:20", timers)
+	}
+
+	clock.Advance(20 * time.Minute)
+	if got := pushes.recorded(); len(got) != 1 {
+		t.Errorf("Got %d pushes, want 1", len(got))
+	}
+}
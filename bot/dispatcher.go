@@ -0,0 +1,179 @@
+package bot
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/azaky/cpbot/logging"
+	"github.com/azaky/cpbot/repository"
+	"github.com/azaky/cpbot/util"
+	"github.com/line/line-bot-sdk-go/linebot"
+)
+
+// pushPriority decides which pushes are still sent when running low on the
+// monthly quota.
+type pushPriority int
+
+const (
+	// pushLow pushes are dropped when running low, e.g. daily reminders
+	// without any contest
+	pushLow pushPriority = iota
+	// pushNormal pushes are only sent to users when running low, not to
+	// groups or rooms
+	pushNormal
+	// pushHigh pushes are sent as long as there is quota left, e.g. pushes
+	// by admins
+	pushHigh
+)
+
+var (
+	errQuotaExhausted = errors.New("Monthly push quota is exhausted")
+	errQuotaLow       = errors.New("Push dropped to save the monthly push quota")
+)
+
+// Line resets push quotas at the start of each month, in Japan time.
+var lineQuotaLocation = time.FixedZone("JST", 9*60*60)
+
+// dispatcher sends every push to Line, no faster than its rate limit allows,
+// and counts them against the monthly quota. Line counts a push to a group
+// or room once for each member, but the dispatcher counts each request once,
+// as members of groups and rooms cannot be counted.
+type dispatcher struct {
+	// send pushes messages in a single request
+	send    func(to string, messages ...linebot.Message) error
+	repo    repository.QuotaStore
+	clock   util.Clock
+	limiter *util.TokenBucket
+	// quota is the number of pushes per month, unlimited if it is 0
+	quota int
+	// reserve is the remaining quota under which only pushes that matter
+	// are sent
+	reserve int
+}
+
+func newDispatcher(client *linebot.Client, repo *repository.Redis, clock util.Clock, rate float64, burst, quota, reserve int) *dispatcher {
+	return &dispatcher{
+		send: func(to string, messages ...linebot.Message) error {
+			_, err := client.PushMessage(to, messages...).Do()
+			return err
+		},
+		repo:    repo,
+		clock:   clock,
+		limiter: util.NewTokenBucket(clock, rate, burst),
+		quota:   quota,
+		reserve: reserve,
+	}
+}
+
+func quotaPeriod(t time.Time) string {
+	return t.In(lineQuotaLocation).Format("2006-01")
+}
+
+// QuotaStatus is the push budget of the current month.
+type QuotaStatus struct {
+	Period string `json:"period"`
+	Used   int    `json:"used"`
+	// Limit is 0 if pushes are unlimited, in which case there is no
+	// Remaining
+	Limit     int  `json:"limit"`
+	Remaining *int `json:"remaining,omitempty"`
+	Reserve   int  `json:"reserve"`
+	// Low is set when only pushes that matter are sent
+	Low bool `json:"low"`
+}
+
+func (d *dispatcher) status() (QuotaStatus, error) {
+	period := quotaPeriod(d.clock.Now())
+	used, err := d.repo.GetQuotaUsed(period)
+	if err != nil {
+		return QuotaStatus{}, err
+	}
+	status := QuotaStatus{Period: period, Used: used, Limit: d.quota, Reserve: d.reserve}
+	if d.quota > 0 {
+		remaining := d.quota - used
+		if remaining < 0 {
+			remaining = 0
+		}
+		status.Remaining = &remaining
+		status.Low = remaining <= d.reserve
+	}
+	return status, nil
+}
+
+// allowedWhenLow reports whether a push of priority to chat is still sent
+// when running low on quota.
+func allowedWhenLow(chat *linebot.EventSource, priority pushPriority) bool {
+	switch priority {
+	case pushHigh:
+		return true
+	case pushNormal:
+		return chat.Type == linebot.EventSourceTypeUser
+	default:
+		return false
+	}
+}
+
+// push sends messages to chat, in as few requests as possible.
+func (d *dispatcher) push(chat *linebot.EventSource, priority pushPriority, messages ...linebot.Message) error {
+	log := logging.WithChat(lineLog, util.LineEventSourceToString(chat))
+	requests := (len(messages) + lineMaxMessagesPerRequest - 1) / lineMaxMessagesPerRequest
+	if requests == 0 {
+		return nil
+	}
+
+	status, err := d.status()
+	if err != nil {
+		// Better to push without counting than to lose the push
+		log.WithError(err).Error("Error getting push quota")
+	} else if status.Low && !allowedWhenLow(chat, priority) {
+		log.Warnf("Dropping push, %d of %d pushes left this month", *status.Remaining, d.quota)
+		linePushesDroppedTotal.WithLabelValues("quota_low").Inc()
+		return errQuotaLow
+	}
+	period := quotaPeriod(d.clock.Now())
+	used, ok, err := d.repo.ReserveQuota(period, requests, d.quota)
+	if err != nil {
+		log.WithError(err).Error("Error reserving push quota")
+	} else if !ok {
+		log.Warnf("Dropping push, %d of %d pushes used this month", used, d.quota)
+		linePushesDroppedTotal.WithLabelValues("quota_exhausted").Inc()
+		return errQuotaExhausted
+	}
+	reserved := err == nil
+
+	to := util.LineEventSourceToReplyString(chat)
+	for len(messages) > 0 {
+		n := len(messages)
+		if n > lineMaxMessagesPerRequest {
+			n = lineMaxMessagesPerRequest
+		}
+		d.limiter.Wait()
+		if err := d.send(to, messages[:n]...); err != nil {
+			log.WithError(err).Error("Error pushing")
+			linePushesTotal.WithLabelValues(metricFailure).Inc()
+			if reserved {
+				// Failed requests are not counted by Line
+				d.repo.ReleaseQuota(period, requests)
+			}
+			return err
+		}
+		linePushesTotal.WithLabelValues(metricSuccess).Inc()
+		messages = messages[n:]
+		requests--
+	}
+	return nil
+}
+
+func (b *LineBot) adminGetQuota(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		writeAdminError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	status, err := b.dispatcher.status()
+	if err != nil {
+		writeAdminError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeAdminJSON(w, http.StatusOK, status)
+}
@@ -0,0 +1,109 @@
+package bot
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/azaky/cpbot/util"
+	"github.com/line/line-bot-sdk-go/linebot"
+)
+
+var (
+	testUser  = &linebot.EventSource{Type: linebot.EventSourceTypeUser, UserID: "U1"}
+	testGroup = &linebot.EventSource{Type: linebot.EventSourceTypeGroup, GroupID: "C1"}
+)
+
+func newTestDispatcher(quota, reserve int) (*dispatcher, *memoryStore, *pushRecorder) {
+	clock := util.NewFakeClock(time.Date(2026, 3, 7, 0, 0, 0, 0, time.UTC))
+	store := newMemoryStore(clock)
+	pushes := &pushRecorder{clock: clock}
+	d := &dispatcher{
+		send:    pushes.send,
+		repo:    store,
+		clock:   clock,
+		limiter: util.NewTokenBucket(clock, 1000, 1000),
+		quota:   quota,
+		reserve: reserve,
+	}
+	return d, store, pushes
+}
+
+func testMessages(n int) []linebot.Message {
+	var messages []linebot.Message
+	for i := 0; i < n; i++ {
+		messages = append(messages, linebot.NewTextMessage("test"))
+	}
+	return messages
+}
+
+func TestDispatcherReservesQuotaPerRequest(t *testing.T) {
+	d, store, pushes := newTestDispatcher(10, 0)
+	if err := d.push(testUser, pushNormal, testMessages(7)...); err != nil {
+		t.Fatalf("Error pushing: %v", err)
+	}
+	got := pushes.recorded()
+	if len(got) != 2 || got[0].Messages != 5 || got[1].Messages != 2 {
+		t.Errorf("Got pushes %v, want 5 and 2 messages", got)
+	}
+	if used, _ := store.GetQuotaUsed(quotaPeriod(d.clock.Now())); used != 2 {
+		t.Errorf("Got %d pushes used, want 2", used)
+	}
+}
+
+func TestDispatcherReleasesQuotaOfFailedRequests(t *testing.T) {
+	d, store, pushes := newTestDispatcher(10, 0)
+	calls := 0
+	pushes.fail = func(to string) error {
+		calls++
+		if calls == 2 {
+			return errors.New("Line is down")
+		}
+		return nil
+	}
+	if err := d.push(testUser, pushNormal, testMessages(12)...); err == nil {
+		t.Fatal("Got no error from a failed push")
+	}
+	// Only the first of three requests went through
+	if used, _ := store.GetQuotaUsed(quotaPeriod(d.clock.Now())); used != 1 {
+		t.Errorf("Got %d pushes used, want 1", used)
+	}
+}
+
+func TestDispatcherDropsPushesWhenQuotaIsExhausted(t *testing.T) {
+	d, store, pushes := newTestDispatcher(2, 0)
+	if err := d.push(testUser, pushHigh, testMessages(10)...); err != nil {
+		t.Fatalf("Error pushing: %v", err)
+	}
+	if err := d.push(testUser, pushHigh, testMessages(1)...); err != errQuotaExhausted {
+		t.Errorf("Got %v pushing over quota, want %v", err, errQuotaExhausted)
+	}
+	if got := pushes.recorded(); len(got) != 2 {
+		t.Errorf("Got %d pushes, want 2", len(got))
+	}
+	if used, _ := store.GetQuotaUsed(quotaPeriod(d.clock.Now())); used != 2 {
+		t.Errorf("Got %d pushes used, want 2", used)
+	}
+}
+
+func TestDispatcherSavesQuotaWhenLow(t *testing.T) {
+	tests := []struct {
+		chat     *linebot.EventSource
+		priority pushPriority
+		want     error
+	}{
+		{testUser, pushLow, errQuotaLow},
+		{testUser, pushNormal, nil},
+		{testUser, pushHigh, nil},
+		{testGroup, pushLow, errQuotaLow},
+		{testGroup, pushNormal, errQuotaLow},
+		{testGroup, pushHigh, nil},
+	}
+	for _, test := range tests {
+		d, store, _ := newTestDispatcher(10, 5)
+		store.ReserveQuota(quotaPeriod(d.clock.Now()), 5, 10)
+		if err := d.push(test.chat, test.priority, testMessages(1)...); err != test.want {
+			t.Errorf("Got %v pushing to %s with priority %d, want %v", err, test.chat.Type, test.priority, test.want)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package bot
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	healthOK   = "ok"
+	healthFail = "fail"
+)
+
+// healthCheck is the result of a single check of /healthz or /readyz.
+type healthCheck struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+	// Last is when the checked thing last happened, if it applies
+	Last *time.Time `json:"last,omitempty"`
+}
+
+type healthResponse struct {
+	Status string                 `json:"status"`
+	Checks map[string]healthCheck `json:"checks"`
+}
+
+// HealthHandler serves liveness: it fails if the daily job has stopped
+// running, in which case cpbot should be restarted.
+func (b *LineBot) HealthHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		writeHealth(w, map[string]healthCheck{
+			"scheduler": b.checkScheduler(),
+		})
+	})
+}
+
+// ReadyHandler serves readiness: it fails if the repository is unreachable,
+// clist.by has not been fetched successfully within clistMaxAge, or the
+// daily job has stopped running.
+func (b *LineBot) ReadyHandler(clistMaxAge time.Duration) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		writeHealth(w, map[string]healthCheck{
+			"repository": b.checkRepository(),
+			"clist":      b.checkClist(clistMaxAge),
+			"scheduler":  b.checkScheduler(),
+		})
+	})
+}
+
+func writeHealth(w http.ResponseWriter, checks map[string]healthCheck) {
+	res := healthResponse{Status: healthOK, Checks: checks}
+	status := http.StatusOK
+	for _, check := range checks {
+		if check.Status != healthOK {
+			res.Status = healthFail
+			status = http.StatusServiceUnavailable
+		}
+	}
+	writeAdminJSON(w, status, res)
+}
+
+func (b *LineBot) checkRepository() healthCheck {
+	if err := b.repo.Ping(); err != nil {
+		return healthCheck{Status: healthFail, Error: err.Error()}
+	}
+	return healthCheck{Status: healthOK}
+}
+
+// clistCheck is the result of the last live check of clist.by.
+type clistCheck struct {
+	mu  sync.Mutex
+	at  time.Time
+	err error
+}
+
+// checkClist checks that clist.by has been fetched successfully within
+// maxAge. A quiet bot might not fetch for a while, so it is fetched right
+// away if it has not, at most once per maxAge: probes in between get the
+// result of the last fetch.
+func (b *LineBot) checkClist(maxAge time.Duration) healthCheck {
+	now := b.clock.Now()
+	last := b.clistService.LastSuccess()
+	if now.Sub(last) <= maxAge {
+		return healthCheck{Status: healthOK, Last: &last}
+	}
+
+	c := &b.clistCheck
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.at.IsZero() || now.Sub(c.at) > maxAge {
+		_, c.err = b.clistService.GetContestsStartingBetween(now, now.Add(time.Hour))
+		c.at = now
+	}
+	if c.err != nil {
+		check := healthCheck{Status: healthFail, Error: c.err.Error()}
+		if !last.IsZero() {
+			check.Last = &last
+		}
+		return check
+	}
+	last = b.clistService.LastSuccess()
+	return healthCheck{Status: healthOK, Last: &last}
+}
+
+func (b *LineBot) checkScheduler() healthCheck {
+	alive, last := b.scheduler.alive()
+	check := healthCheck{Status: healthOK}
+	if !last.IsZero() {
+		check.Last = &last
+	}
+	if !alive {
+		check.Status = healthFail
+		check.Error = "daily job is not running"
+	}
+	return check
+}
@@ -0,0 +1,39 @@
+package bot
+
+import (
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/azaky/cpbot/clist"
+	"github.com/azaky/cpbot/util"
+)
+
+func TestCheckClistFetchesAtMostOncePerMaxAge(t *testing.T) {
+	clock := util.NewFakeClock(time.Date(2026, 3, 7, 0, 0, 0, 0, time.UTC))
+	var requests int32
+	b := &LineBot{
+		clock: clock,
+		clistService: clist.NewService("test:test", &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			atomic.AddInt32(&requests, 1)
+			return nil, errNotSet
+		})}),
+	}
+
+	for i := 0; i < 5; i++ {
+		if check := b.checkClist(time.Minute); check.Status != healthFail {
+			t.Fatalf("Got %s with clist.by down, want %s", check.Status, healthFail)
+		}
+		clock.Advance(10 * time.Second)
+	}
+	if requests != 1 {
+		t.Errorf("Got %d requests to clist.by within a minute, want 1", requests)
+	}
+
+	clock.Advance(time.Minute)
+	b.checkClist(time.Minute)
+	if requests != 2 {
+		t.Errorf("Got %d requests to clist.by after a minute, want 2", requests)
+	}
+}
@@ -1,38 +1,75 @@
 package bot
 
 import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
-	"log"
 	"net/http"
-	"os"
 	"regexp"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/azaky/cpbot/clist"
+	"github.com/azaky/cpbot/config"
+	"github.com/azaky/cpbot/logging"
 	"github.com/azaky/cpbot/repository"
 	"github.com/azaky/cpbot/util"
 	"github.com/line/line-bot-sdk-go/linebot"
+	"github.com/sirupsen/logrus"
 )
 
 type messageHandler func(linebot.Event, ...string)
 type patternHandler struct {
+	Name    string
 	Pattern *regexp.Regexp
 	Handler messageHandler
 }
 type LineBot struct {
 	clistService *clist.Service
+	clock        util.Clock
 	client       *linebot.Client
 	repo         *repository.Redis
-	dailyTicker  *time.Ticker
-	dailyTimer   map[string]*time.Timer
-	dailyNext    time.Time
-	dailyPeriod  time.Duration
+	events       repository.EventStore
+	// daily, users and rates are repo, as far as delivering reminders,
+	// broadcasting and rate limiting commands are concerned
+	daily        repository.DailyStore
+	users        repository.UserStore
+	rates        repository.RateStore
+	scheduler    *scheduler
+	queue        *eventQueue
+	dispatcher   *dispatcher
+	config       config.Line
+	dailyGrace   time.Duration
+	clistCheck   clistCheck
 	textPatterns []patternHandler
+	// sendReply replies messages to a reply token in a single request
+	sendReply func(replyToken string, messages ...linebot.Message) error
+	// requestIDs maps the ids of events being processed to the webhook
+	// requests they came in
+	requestIDs sync.Map
 }
 
-var (
-	lineMaxMessageLength, _ = strconv.Atoi(os.Getenv("LINE_MAX_MESSAGE_LENGTH"))
+const (
+	lineMaxDailyTimes = 5
+	// Line accepts at most 5 messages per reply/push request
+	lineMaxMessagesPerRequest = 5
+	// Limits of Line buttons template
+	lineMaxTemplateActions   = 4
+	lineMaxActionLabelLength = 20
+	// Every instance schedules the same reminders, the first one to claim
+	// an occurrence delivers it. A claimed occurrence is delivered again if
+	// it has not been delivered this long after, e.g. because the instance
+	// delivering it died. It must outlast a delivery, including waiting for
+	// the push rate limit.
+	lineDailyClaimTTL = 5 * time.Minute
+	// Line redelivers events within minutes, if ever
+	lineEventDedupTTL = time.Hour
+	// Timezone of new chats, and of chats whose settings are reset
+	lineDefaultTimezone = "Asia/Jakarta"
 )
 
 const (
@@ -45,13 +82,34 @@ Type "@cpbot help" for the complete list of commands.`
 	lineHelpString = `Here are available commands:
 
 @cpbot set daily HH:MM -> Set daily reminder for contests
-@cpbot unset daily -> Turn off daily contest reminder
+@cpbot set daily HH:MM window 48h -> Set daily reminder for contests in the next 48h ("today" for until midnight)
+@cpbot add daily HH:MM -> Add another daily reminder time
+@cpbot remove daily HH:MM -> Remove a daily reminder time
+@cpbot set daily skip-empty on -> Do not send daily reminder when there is no contest
+@cpbot unset daily -> Turn off all daily contest reminders
 @cpbot get daily -> Show current daily setting
 
 @cpbot in 3h30m -> Show contests starting in 3h30m
 
-@cpbot set timezone Asia/Jakarta -> Set timezone
+@cpbot set quiet 23:00-07:00 -> Hold reminders during quiet hours, and send them when quiet hours end
+@cpbot unset quiet -> Turn off quiet hours
+@cpbot get quiet -> Show current quiet hours
+
+@cpbot set timezone Asia/Jakarta -> Set timezone. A city (Tokyo) or a country (Indonesia) works too
 @cpbot get timezone -> Get current timezone setting
+@cpbot set my timezone Asia/Tokyo -> Set your own timezone, used to show times to you in groups
+@cpbot get my timezone -> Get your own timezone setting
+
+@cpbot settings -> Show all settings of this chat
+@cpbot reset -> Restore default settings
+@cpbot export -> Get a code to copy settings of this chat to another chat
+@cpbot import CODE -> Apply settings exported from another chat
+
+In groups, only admins can change settings. The first member to change a setting becomes an admin.
+@cpbot admin list -> Show admins of this group
+@cpbot admin add USER_ID -> Make a member an admin
+@cpbot admin remove USER_ID -> Remove an admin
+@cpbot whoami -> Show your user ID
 
 @cpbot about -> Show info about this bot
 @cpbot help -> Show this`
@@ -64,49 +122,167 @@ Credits:
 - Logo by Roland Hartanto`
 )
 
-func NewLineBot(channelSecret, channelToken string, clistService *clist.Service, redisEndpoint string) *LineBot {
-	bot, err := linebot.New(channelSecret, channelToken)
+func NewLineBot(cfg config.Line, clistService *clist.Service, redisEndpoint string) *LineBot {
+	bot, err := linebot.New(cfg.ChannelSecret, cfg.ChannelToken)
 	if err != nil {
-		log.Fatalf("Error when initializing linebot: %s", err.Error())
+		lineLog.WithError(err).Fatal("Error initializing linebot")
 	}
 	repo := repository.NewRedis("line", redisEndpoint)
+	settings, daily, err := repo.Migrate(time.Now())
+	if settings > 0 {
+		lineLog.Infof("Migrated %d settings", settings)
+	}
+	if daily > 0 {
+		lineLog.Infof("Migrated %d daily entries", daily)
+	}
+	if err != nil {
+		lineLog.WithError(err).Error("Error migrating repository")
+	}
+	registerActiveChats(repo)
 	b := &LineBot{
 		clistService: clistService,
+		config:       cfg,
+		clock:        util.RealClock,
 		client:       bot,
 		repo:         repo,
+		events:       repo,
+		daily:        repo,
+		users:        repo,
+		rates:        repo,
+		scheduler:    newScheduler(util.RealClock),
 	}
+	b.sendReply = func(replyToken string, messages ...linebot.Message) error {
+		_, err := bot.ReplyMessage(replyToken, messages...).Do()
+		return err
+	}
+	b.dispatcher = newDispatcher(bot, repo, b.clock, cfg.PushRate, cfg.PushBurst, cfg.PushQuota, cfg.PushQuotaReserve)
+	b.queue = newEventQueue(b.clock, cfg.Workers, cfg.QueueSize, b.handleEvent)
+	registerQueueDepth(b.queue)
+
+	b.registerTextPattern("help", `^\s*@cpbot\s*(?:help\s*)?$`, b.actionShowHelp)
+	b.registerTextPattern("about", `^\s*@cpbot\s*(?:about\s*)?$`, b.actionShowAbout)
+
+	b.registerTextPattern("in", `^\s*@cpbot\s+in\s*(\S+)?\s*$`, b.actionShowContestsWithin)
+
+	b.registerTextPattern("unset_daily", `^\s*@cpbot\s+unset\s*daily\s*$`, b.adminOnly(b.actionRemoveAllDaily))
+	b.registerTextPattern("add_daily", `^\s*@cpbot\s+add\s+daily\s*(\S+)?\s*$`, b.adminOnly(b.actionAddDaily))
+	b.registerTextPattern("remove_daily", `^\s*@cpbot\s+remove\s+daily\s*(\S+)?\s*$`, b.adminOnly(b.actionRemoveDaily))
+	b.registerTextPattern("set_daily_skip_empty", `^\s*@cpbot\s+(?:set\s*)?daily\s+skip-empty\s*(\S+)?\s*$`, b.adminOnly(b.actionSetDailySkipEmpty))
+	b.registerTextPattern("set_daily", `^\s*@cpbot\s+(?:set\s*)?daily\s*(\S+)?(?:\s+window\s+(\S+))?\s*$`, b.adminOnly(b.actionUpdateDaily))
+	b.registerTextPattern("get_daily", `^\s*@cpbot\s+(?:get\s+)daily\s*$`, b.actionGetDaily)
+
+	b.registerTextPattern("unset_quiet", `^\s*@cpbot\s+unset\s*quiet\s*$`, b.adminOnly(b.actionRemoveQuiet))
+	b.registerTextPattern("set_quiet", `^\s*@cpbot\s+(?:set\s*)?quiet\s*(\S+)?\s*$`, b.adminOnly(b.actionSetQuiet))
+	b.registerTextPattern("get_quiet", `^\s*@cpbot\s+(?:get\s+)quiet\s*$`, b.actionGetQuiet)
 
-	b.registerTextPattern(`^\s*@cpbot\s*(?:help\s*)?$`, b.actionShowHelp)
-	b.registerTextPattern(`^\s*@cpbot\s*(?:about\s*)?$`, b.actionShowAbout)
+	b.registerTextPattern("set_timezone", `^\s*@cpbot\s+(?:set\s*)?timezone\s*(.*?)\s*$`, b.adminOnly(b.actionSetTimezone))
+	b.registerTextPattern("get_timezone", `^\s*@cpbot\s+(?:get\s+)timezone\s*$`, b.actionGetTimezone)
+	b.registerTextPattern("set_my_timezone", `^\s*@cpbot\s+(?:set\s+)?my\s+timezone\s*(.*?)\s*$`, b.actionSetMyTimezone)
+	b.registerTextPattern("get_my_timezone", `^\s*@cpbot\s+get\s+my\s+timezone\s*$`, b.actionGetMyTimezone)
 
-	b.registerTextPattern(`^\s*@cpbot\s+in\s*(\S+)?\s*$`, b.actionShowContestsWithin)
+	b.registerTextPattern("settings", `^\s*@cpbot\s+settings\s*$`, b.actionShowSettings)
+	b.registerTextPattern("reset", `^\s*@cpbot\s+reset\s*$`, b.adminOnly(b.actionReset))
+	b.registerTextPattern("reset_confirm", `^\s*@cpbot\s+reset\s+confirm\s*$`, b.adminOnly(b.actionResetConfirm))
+	b.registerTextPattern("reset_cancel", `^\s*@cpbot\s+reset\s+cancel\s*$`, b.actionResetCancel)
 
-	b.registerTextPattern(`^\s*@cpbot\s+unset\s*daily\s*$`, b.actionRemoveDaily)
-	b.registerTextPattern(`^\s*@cpbot\s+(?:set\s*)?daily\s*(\S+)?\s*$`, b.actionUpdateDaily)
-	b.registerTextPattern(`^\s*@cpbot\s+(?:get\s+)daily\s*$`, b.actionGetDaily)
+	b.registerTextPattern("export", `^\s*@cpbot\s+export\s*$`, b.actionExport)
+	b.registerTextPattern("import", `^\s*@cpbot\s+import\s*((?s:.*?))\s*$`, b.adminOnly(b.actionImport))
 
-	b.registerTextPattern(`^\s*@cpbot\s+(?:set\s*)?timezone\s*(\S+)?\s*$`, b.actionSetTimezone)
-	b.registerTextPattern(`^\s*@cpbot\s+(?:get\s+)timezone\s*$`, b.actionGetTimezone)
+	b.registerTextPattern("admin_list", `^\s*@cpbot\s+admins?(?:\s+list)?\s*$`, b.actionListAdmins)
+	b.registerTextPattern("admin_add", `^\s*@cpbot\s+admin\s+add\s*(\S+)?\s*$`, b.adminOnly(b.actionAddAdmin))
+	b.registerTextPattern("admin_remove", `^\s*@cpbot\s+admin\s+remove\s*(\S+)?\s*$`, b.adminOnly(b.actionRemoveAdmin))
+	b.registerTextPattern("whoami", `^\s*@cpbot\s+whoami\s*$`, b.actionWhoAmI)
 
-	b.registerTextPattern(`^\s*@cpbot\s+(.*)$`, b.actionUnknown)
+	b.registerTextPattern("unknown", `^\s*@cpbot\s+(.*)$`, b.actionUnknown)
 
 	return b
 }
 
-func (b *LineBot) registerTextPattern(regex string, handler messageHandler) {
+// registerTextPattern registers handler for text messages matching regex.
+// name identifies the command in metrics, so it must not change.
+func (b *LineBot) registerTextPattern(name, regex string, handler messageHandler) {
 	r, err := regexp.Compile(`(?i)` + regex)
 	if err != nil {
-		b.log("Error registering text pattern: %s", err.Error())
+		lineLog.WithError(err).Errorf("Error registering text pattern %s", name)
 		return
 	}
 	b.textPatterns = append(b.textPatterns, patternHandler{
+		Name:    name,
 		Pattern: r,
 		Handler: handler,
 	})
 }
 
-func (b *LineBot) log(format string, args ...interface{}) {
-	log.Printf("[LINE] "+format, args...)
+// adminOnly restricts handler to admins when used in a group or a room.
+func (b *LineBot) adminOnly(handler messageHandler) messageHandler {
+	return func(event linebot.Event, args ...string) {
+		if event.Source.Type == linebot.EventSourceTypeUser {
+			handler(event, args...)
+			return
+		}
+
+		chat := util.LineEventSourceToString(event.Source)
+		if event.Source.UserID == "" {
+			b.reply(event, "Sorry, I can't tell who you are, so I can't tell if you are an admin of this group. Add me as a friend first")
+			return
+		}
+		ok, err := b.repo.AuthorizeAdmin(chat, event.Source.UserID)
+		if err != nil {
+			b.eventLog(event).WithError(err).Error("Error authorizing admin")
+			b.reply(event, "Error checking admins, please try again in a few moments")
+			return
+		}
+		if !ok {
+			reply := fmt.Sprintf("Sorry, only admins can change settings of this group. Ask an admin to add you with:\n\n@cpbot admin add %s", event.Source.UserID)
+			b.reply(event, reply)
+			return
+		}
+		handler(event, args...)
+	}
+}
+
+var (
+	lineLog      = logrus.WithField(logging.FieldPlatform, "line")
+	dailyLog     = lineLog.WithField(logging.FieldComponent, "daily")
+	quietLog     = lineLog.WithField(logging.FieldComponent, "quiet")
+	adminLog     = lineLog.WithField(logging.FieldComponent, "admin")
+	broadcastLog = lineLog.WithField(logging.FieldComponent, "broadcast")
+)
+
+// eventLog returns the logger of everything done in response to event,
+// including the id of the webhook request it came in while it is processed.
+func (b *LineBot) eventLog(event linebot.Event) *logrus.Entry {
+	id := lineEventID(event)
+	e := logging.WithChat(lineLog, util.LineEventSourceToString(event.Source)).WithField(logging.FieldEventID, id)
+	if event.Source.UserID != "" {
+		e = e.WithField(logging.FieldUser, logging.ChatID(event.Source.UserID))
+	}
+	if requestID, ok := b.requestIDs.Load(id); ok {
+		e = e.WithField(logging.FieldRequestID, requestID)
+	}
+	return e
+}
+
+// lineEventID identifies event, as Line webhook events do not have an id:
+// it is the id of the message for message events, or else derived from the
+// reply token or the timestamp of the event.
+func lineEventID(event linebot.Event) string {
+	if message, ok := event.Message.(*linebot.TextMessage); ok && message.ID != "" {
+		return "message:" + message.ID
+	}
+	key := event.ReplyToken
+	if key == "" {
+		key = fmt.Sprintf("%s:%s:%d", event.Type, util.LineEventSourceToString(event.Source), event.Timestamp.UnixNano())
+	}
+	sum := sha256.Sum256([]byte(key))
+	return string(event.Type) + ":" + hex.EncodeToString(sum[:8])
+}
+
+// newRequestID returns a random id for a webhook request.
+func newRequestID() string {
+	buf := make([]byte, 8)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
 }
 
 func (b *LineBot) reply(event linebot.Event, messages ...string) error {
@@ -114,23 +290,26 @@ func (b *LineBot) reply(event linebot.Event, messages ...string) error {
 	for _, message := range messages {
 		lineMessages = append(lineMessages, linebot.NewTextMessage(message))
 	}
-	_, err := b.client.ReplyMessage(event.ReplyToken, lineMessages...).Do()
+	return b.replyMessages(event, lineMessages...)
+}
+
+func (b *LineBot) replyMessages(event linebot.Event, lineMessages ...linebot.Message) error {
+	err := b.sendReply(event.ReplyToken, lineMessages...)
 	if err != nil {
-		b.log("Error replying to %+v: %s", event.Source, err.Error())
+		b.eventLog(event).WithError(err).Error("Error replying")
+		lineRepliesTotal.WithLabelValues(metricFailure).Inc()
+	} else {
+		lineRepliesTotal.WithLabelValues(metricSuccess).Inc()
 	}
 	return err
 }
 
-func (b *LineBot) push(to string, messages ...string) error {
+func (b *LineBot) push(chat *linebot.EventSource, priority pushPriority, messages ...string) error {
 	var lineMessages []linebot.Message
 	for _, message := range messages {
 		lineMessages = append(lineMessages, linebot.NewTextMessage(message))
 	}
-	_, err := b.client.PushMessage(to, lineMessages...).Do()
-	if err != nil {
-		b.log("Error pushing to %s: %s", to, err.Error())
-	}
-	return err
+	return b.dispatcher.push(chat, priority, lineMessages...)
 }
 
 func (b *LineBot) EventHandler(w http.ResponseWriter, req *http.Request) {
@@ -144,25 +323,69 @@ func (b *LineBot) EventHandler(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
+	// Events are processed in the background, so that Line does not time
+	// out waiting for slow commands. If some cannot be queued, the request
+	// fails, which Line redelivers only if webhook redelivery is enabled on
+	// the channel. Events already queued are deduplicated then.
+	requestID := newRequestID()
+	rejected := 0
 	for _, event := range events {
-		b.log("[EVENT][%s] Source: %#v", event.Type, event.Source)
-		switch event.Type {
-
-		case linebot.EventTypeJoin:
-			fallthrough
-		case linebot.EventTypeFollow:
-			b.handleFollow(event)
-
-		case linebot.EventTypeLeave:
-			fallthrough
-		case linebot.EventTypeUnfollow:
-			b.handleUnfollow(event)
-
-		case linebot.EventTypeMessage:
-			switch message := event.Message.(type) {
-			case *linebot.TextMessage:
-				b.handleTextMessage(event, message)
-			}
+		log := b.eventLog(event).WithField(logging.FieldRequestID, requestID)
+		log.Infof("Received %s event", event.Type)
+		lineEventsTotal.WithLabelValues(string(event.Type)).Inc()
+		if !b.queue.push(event, requestID) {
+			log.Warn("Queue is full, rejecting event")
+			lineQueueRejectedTotal.Inc()
+			rejected++
+		}
+	}
+	if rejected > 0 {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// handleEvent processes event, which came in the webhook request requestID,
+// unless it has been processed before.
+func (b *LineBot) handleEvent(event linebot.Event, requestID string) {
+	id := lineEventID(event)
+	b.requestIDs.Store(id, requestID)
+	defer b.requestIDs.Delete(id)
+
+	processed, err := b.events.IsEventProcessed(id)
+	if err != nil {
+		// Better to process an event twice than to lose it
+		b.eventLog(event).WithError(err).Error("Error deduplicating event")
+	} else if processed {
+		b.eventLog(event).Info("Skipping duplicate event")
+		lineEventsDuplicateTotal.Inc()
+		return
+	}
+	// Only marked once processed, so that a redelivery of an event that was
+	// never processed, e.g. because of a shutdown, is not skipped
+	defer func() {
+		if err := b.events.MarkEventProcessed(id, lineEventDedupTTL); err != nil {
+			b.eventLog(event).WithError(err).Error("Error marking event as processed")
+		}
+	}()
+
+	switch event.Type {
+
+	case linebot.EventTypeJoin:
+		fallthrough
+	case linebot.EventTypeFollow:
+		b.handleFollow(event)
+
+	case linebot.EventTypeLeave:
+		fallthrough
+	case linebot.EventTypeUnfollow:
+		b.handleUnfollow(event)
+
+	case linebot.EventTypeMessage:
+		switch message := event.Message.(type) {
+		case *linebot.TextMessage:
+			b.handleTextMessage(event, message)
 		}
 	}
 }
@@ -171,7 +394,7 @@ func (b *LineBot) generateGreetingMessage(tz *time.Location) []linebot.Message {
 	var messages []linebot.Message
 	messages = append(messages, linebot.NewTextMessage(lineGreetingMessage))
 
-	initialReminder, err := generate24HUpcomingContestsMessage(b.clistService, tz, lineMaxMessageLength)
+	initialReminder, err := generate24HUpcomingContestsMessage(b.clistService, b.clock.Now(), tz, b.config.MaxMessageLength)
 	if err == nil {
 		for _, message := range initialReminder {
 			messages = append(messages, linebot.NewTextMessage(message))
@@ -185,20 +408,31 @@ func (b *LineBot) handleFollow(event linebot.Event) {
 	user := util.LineEventSourceToString(event.Source)
 	_, err := b.repo.AddUser(user)
 	if err != nil {
-		b.log("Error adding user: %s", err.Error())
+		b.eventLog(event).WithError(err).Error("Error adding user")
 	}
 
-	// Set default timezone to Asia/Jakarta
-	tz, _ := util.LoadLocation("Asia/Jakarta")
-	b.repo.SetTimezone(user, "Asia/Jakarta")
+	// Set default timezone, unless the chat kept its own from before it
+	// unfollowed or removed the bot. Personal timezones are kept apart and
+	// do not count.
+	tz, err := b.repo.GetTimezone(user)
+	if err != nil {
+		tz, _ = util.LoadLocation(lineDefaultTimezone)
+		b.repo.SetTimezone(user, lineDefaultTimezone)
+	}
 
 	messages := b.generateGreetingMessage(tz)
-	if _, err = b.client.ReplyMessage(event.ReplyToken, messages...).Do(); err != nil {
-		b.log("Error replying to follow event: %s", err.Error())
+	if err = b.sendReply(event.ReplyToken, messages...); err != nil {
+		b.eventLog(event).WithError(err).Error("Error replying to follow event")
 	}
 
-	// Setup default daily reminder
-	t, _ := util.ParseTime(os.Getenv("LINE_DAILY_DEFAULT"))
+	b.setDefaultDaily(user, tz)
+}
+
+// setDefaultDaily sets the daily reminder of user to the configured default,
+// which is in UTC.
+func (b *LineBot) setDefaultDaily(user string, tz *time.Location) {
+	utc, _ := util.ParseTime(b.config.DailyDefault)
+	t := util.TimeToInt(util.NextTime(b.clock.Now(), utc, time.UTC).In(tz))
 	b.updateDaily(user, t)
 }
 
@@ -206,15 +440,32 @@ func (b *LineBot) handleUnfollow(event linebot.Event) {
 	user := util.LineEventSourceToString(event.Source)
 	_, err := b.repo.RemoveUser(user)
 	if err != nil {
-		b.log("Error removing user: %s", err.Error())
+		b.eventLog(event).WithError(err).Error("Error removing user")
+	}
+	if _, err = b.repo.RemoveAdmins(user); err != nil {
+		b.eventLog(event).WithError(err).Error("Error removing admins")
 	}
 }
 
 func (b *LineBot) handleTextMessage(event linebot.Event, message *linebot.TextMessage) {
-	log.Printf("Received message from %s: %s", event.Source.UserID, message.Text)
+	b.eventLog(event).WithField(logging.FieldText, logging.Content(message.Text)).Debug("Received message")
+	// Every command mentions the bot, so other messages, and messages too
+	// long to be a command, are not matched against every pattern
+	if len(message.Text) > b.config.MaxInputLength {
+		b.eventLog(event).Debugf("Ignoring message of %d bytes", len(message.Text))
+		return
+	}
+	if !strings.Contains(strings.ToLower(message.Text), "@cpbot") {
+		return
+	}
 	for _, p := range b.textPatterns {
 		matches := p.Pattern.FindStringSubmatch(message.Text)
 		if matches != nil {
+			b.eventLog(event).WithField(logging.FieldCommand, p.Name).Info("Matched command")
+			lineCommandsTotal.WithLabelValues(p.Name).Inc()
+			if !b.allowCommand(event) {
+				return
+			}
 			p.Handler(event, matches...)
 			return
 		}
@@ -234,27 +485,39 @@ func (b *LineBot) actionShowContestsWithin(event linebot.Event, args ...string)
 		b.reply(event, `Duration is required for "in" command. Example:
 
 @cpbot in 10h`)
+		return
 	}
 	duration, err := time.ParseDuration(args[1])
-	if err != nil {
+	if err != nil || duration <= 0 {
 		// Duration is not valid
 		reply := fmt.Sprintf("%s is not a valid duration", args[1])
 		b.reply(event, reply)
 		return
 	}
+	if max := b.maxDuration(); duration > max {
+		reply := fmt.Sprintf("Duration must be at most %s", formatDuration(max))
+		b.reply(event, reply)
+		return
+	}
 
-	user := util.LineEventSourceToString(event.Source)
-	tz, _ := b.repo.GetTimezone(user)
+	tz := b.timezoneFor(event)
 
-	replies, err := generateUpcomingContestsMessage(b.clistService, time.Now(), time.Now().Add(duration), tz, fmt.Sprintf("Contests starting within %s:", args[1]), lineMaxMessageLength)
+	now := b.clock.Now()
+	replies, err := generateUpcomingContestsMessage(b.clistService, now, now.Add(duration), tz, fmt.Sprintf("Contests starting within %s:", args[1]), b.config.MaxMessageLength)
 	if err != nil {
-		b.log("Error getting contests: %s", err.Error())
+		b.eventLog(event).WithError(err).Error("Error getting contests")
 		return
 	}
 
 	b.reply(event, replies...)
 }
 
+// maxDuration is the longest range of contests shown at once, by the "in"
+// command or by daily reminders.
+func (b *LineBot) maxDuration() time.Duration {
+	return time.Duration(b.config.MaxInDuration) * time.Second
+}
+
 func (b *LineBot) actionUpdateDaily(event linebot.Event, args ...string) {
 	tstr := args[1]
 	user := util.LineEventSourceToString(event.Source)
@@ -264,23 +527,87 @@ func (b *LineBot) actionUpdateDaily(event linebot.Event, args ...string) {
 @cpbot set daily 09:00`)
 		return
 	}
-	tz, _ := b.repo.GetTimezone(user)
-
-	t, err := util.ParseTimeInLocation(tstr, tz)
+	t, err := util.ParseTime(tstr)
 	if err != nil {
 		reply := fmt.Sprintf("%s is not a valid time", tstr)
 		b.reply(event, reply)
 		return
 	}
 
+	window := args[2]
+	if window != "" {
+		window, err = parseDailyWindow(window, b.maxDuration())
+		if err != nil {
+			reply := fmt.Sprintf(`%s is not a valid window. Use a duration of at most %s such as "48h", or "today"`, args[2], formatDuration(b.maxDuration()))
+			b.reply(event, reply)
+			return
+		}
+		b.repo.SetDailyWindow(user, window)
+	} else {
+		window, _ = b.repo.GetDailyWindow(user)
+	}
+
 	b.updateDaily(user, t)
-	reply := fmt.Sprintf("Daily contest reminder has been set everyday at %s", tstr)
+	reply := fmt.Sprintf("Daily contest reminder has been set everyday at %s, covering contests in %s", tstr, describeDailyWindow(window))
+	b.reply(event, reply)
+}
+
+func (b *LineBot) actionAddDaily(event linebot.Event, args ...string) {
+	tstr := args[1]
+	user := util.LineEventSourceToString(event.Source)
+	if tstr == "" {
+		b.reply(event, `Time is required for "add daily" command. Example:
+
+@cpbot add daily 20:00`)
+		return
+	}
+	t, err := util.ParseTime(tstr)
+	if err != nil {
+		reply := fmt.Sprintf("%s is not a valid time", tstr)
+		b.reply(event, reply)
+		return
+	}
+
+	times, _ := b.repo.GetDaily(user)
+	if len(times) >= lineMaxDailyTimes {
+		reply := fmt.Sprintf("You can only have up to %d daily reminders. Remove one first with:\n\n@cpbot remove daily HH:MM", lineMaxDailyTimes)
+		b.reply(event, reply)
+		return
+	}
+
+	b.addDaily(user, t)
+	reply := fmt.Sprintf("Daily contest reminder has been added everyday at %s", tstr)
 	b.reply(event, reply)
 }
 
 func (b *LineBot) actionRemoveDaily(event linebot.Event, args ...string) {
+	tstr := args[1]
+	user := util.LineEventSourceToString(event.Source)
+	if tstr == "" {
+		b.reply(event, `Time is required for "remove daily" command. Example:
+
+@cpbot remove daily 20:00`)
+		return
+	}
+	t, err := util.ParseTime(tstr)
+	if err != nil {
+		reply := fmt.Sprintf("%s is not a valid time", tstr)
+		b.reply(event, reply)
+		return
+	}
+
+	if !b.removeDaily(user, t) {
+		reply := fmt.Sprintf("There is no daily contest reminder at %s", tstr)
+		b.reply(event, reply)
+		return
+	}
+	reply := fmt.Sprintf("Daily contest reminder at %s has been removed", tstr)
+	b.reply(event, reply)
+}
+
+func (b *LineBot) actionRemoveAllDaily(event linebot.Event, args ...string) {
 	user := util.LineEventSourceToString(event.Source)
-	b.removeDaily(user)
+	b.removeAllDaily(user)
 	reply := "Daily contest reminder has been turned off"
 	b.reply(event, reply)
 }
@@ -289,40 +616,210 @@ func (b *LineBot) actionGetDaily(event linebot.Event, args ...string) {
 	user := util.LineEventSourceToString(event.Source)
 	daily, err := b.getDaily(user)
 	var reply string
-	if err != nil {
+	if err != nil || len(daily) == 0 {
 		reply = "Daily has not been set. Set it with the following command:\n\n@cpbot set daily HH:MM"
 	} else {
-		reply = fmt.Sprintf("Daily contest reminder is set at %s everyday", daily)
+		window, _ := b.repo.GetDailyWindow(user)
+		reply = fmt.Sprintf("Daily contest reminder is set at %s everyday, covering contests in %s", strings.Join(daily, ", "), describeDailyWindow(window))
+		if b.dailySkipEmpty(user) {
+			reply += ". It is not sent when there is no contest"
+		}
 	}
 	b.reply(event, reply)
 }
 
-func (b *LineBot) actionSetTimezone(event linebot.Event, args ...string) {
-	tz := args[1]
+func (b *LineBot) actionSetDailySkipEmpty(event linebot.Event, args ...string) {
 	user := util.LineEventSourceToString(event.Source)
-	if tz == "" {
-		b.reply(event, `Timezone is required for "set timezone" command. Example:
+	var skip bool
+	switch strings.ToLower(args[1]) {
+	case "on":
+		skip = true
+	case "off":
+		skip = false
+	default:
+		b.reply(event, `Either "on" or "off" is required for "set daily skip-empty" command. Example:
+
+@cpbot set daily skip-empty on`)
+		return
+	}
 
-@cpbot set timezone UTC+10`)
+	if _, err := b.repo.SetDailySkipEmpty(user, skip); err != nil {
+		b.eventLog(event).WithError(err).Error("Error setting daily skip-empty")
+		b.reply(event, "Error setting daily reminder, please try again in a few moments")
 		return
 	}
-	_, err := util.LoadLocation(tz)
-	if err != nil {
-		reply := fmt.Sprintf("%s is not a valid timezone. Timezone is not changed", tz)
+	if skip {
+		b.reply(event, "Daily contest reminder will not be sent when there is no contest")
+	} else {
+		b.reply(event, "Daily contest reminder will be sent even when there is no contest")
+	}
+}
+
+func (b *LineBot) actionSetQuiet(event linebot.Event, args ...string) {
+	qstr := args[1]
+	user := util.LineEventSourceToString(event.Source)
+	if qstr == "" {
+		b.reply(event, `Time range is required for "set quiet" command. Example:
+
+@cpbot set quiet 23:00-07:00`)
+		return
+	}
+
+	from, to, err := util.ParseTimeRange(qstr)
+	if err != nil || from == to {
+		reply := fmt.Sprintf("%s is not a valid time range", qstr)
 		b.reply(event, reply)
 		return
 	}
 
+	quiet := fmt.Sprintf("%s-%s", util.FormatTimeOfDay(from), util.FormatTimeOfDay(to))
+	if _, err = b.repo.SetQuiet(user, quiet); err != nil {
+		b.eventLog(event).WithError(err).Error("Error setting quiet")
+		b.reply(event, "Error setting quiet hours, please try again in a few moments")
+		return
+	}
+	reply := fmt.Sprintf("Quiet hours are set from %s to %s everyday. Reminders during quiet hours will be sent when they end", util.FormatTimeOfDay(from), util.FormatTimeOfDay(to))
+	b.reply(event, reply)
+}
+
+func (b *LineBot) actionRemoveQuiet(event linebot.Event, args ...string) {
+	user := util.LineEventSourceToString(event.Source)
+	b.repo.RemoveQuiet(user)
+	reply := "Quiet hours have been turned off"
+	b.reply(event, reply)
+}
+
+func (b *LineBot) actionGetQuiet(event linebot.Event, args ...string) {
+	user := util.LineEventSourceToString(event.Source)
+	quiet, err := b.repo.GetQuiet(user)
+	var reply string
+	if err != nil {
+		reply = "Quiet hours have not been set. Set them with the following command:\n\n@cpbot set quiet 23:00-07:00"
+	} else {
+		reply = fmt.Sprintf("Quiet hours are set from %s everyday", strings.Replace(quiet, "-", " to ", 1))
+	}
+	b.reply(event, reply)
+}
+
+func (b *LineBot) actionSetTimezone(event linebot.Event, args ...string) {
+	user := util.LineEventSourceToString(event.Source)
+	tz, ok := b.parseTimezone(event, args[1], "set timezone")
+	if !ok {
+		return
+	}
+
 	b.repo.SetTimezone(user, tz)
+	// Daily reminders stay at the same wall clock time in the new timezone
+	b.rescheduleDaily(user)
 	reply := fmt.Sprintf("Timezone is set to %s", tz)
 	b.reply(event, reply)
 }
 
+func (b *LineBot) actionSetMyTimezone(event linebot.Event, args ...string) {
+	user := util.LineEventSenderToString(event.Source)
+	if user == "" {
+		b.reply(event, "Sorry, I can't tell who you are. Add me as a friend first to set your own timezone")
+		return
+	}
+	tz, ok := b.parseTimezone(event, args[1], "set my timezone")
+	if !ok {
+		return
+	}
+
+	if _, err := b.repo.SetMyTimezone(user, tz); err != nil {
+		b.eventLog(event).WithError(err).Error("Error setting personal timezone")
+		b.reply(event, "Error setting your timezone, please try again in a few moments")
+		return
+	}
+	reply := fmt.Sprintf("Your own timezone is set to %s", tz)
+	b.reply(event, reply)
+}
+
+// parseTimezone resolves tz, given to the given command, into the name of a
+// timezone. If it cannot, it replies why, or with the timezones to choose
+// from, and returns false.
+func (b *LineBot) parseTimezone(event linebot.Event, tz, command string) (string, bool) {
+	if tz == "" {
+		reply := fmt.Sprintf(`Timezone is required for "%s" command. Example:
+
+@cpbot %s UTC+10`, command, command)
+		b.reply(event, reply)
+		return "", false
+	}
+	loc, err := util.LoadLocation(tz)
+	if err != nil {
+		// Maybe it is the name of a city or a country
+		zones := util.FindTimezones(tz)
+		if len(zones) > 1 {
+			b.replyTimezoneChoices(event, tz, zones, command)
+			return "", false
+		}
+		if len(zones) == 1 {
+			loc, err = util.LoadLocation(zones[0])
+		}
+	}
+	if err != nil {
+		reply := fmt.Sprintf("%s is not a valid timezone. Timezone is not changed", tz)
+		if suggestions := util.SuggestTimezones(tz, 3); len(suggestions) > 0 {
+			reply += fmt.Sprintf(". Did you mean %s?", strings.Join(suggestions, " or "))
+		} else {
+			reply += `. Use a name such as "Asia/Jakarta", or an offset such as "UTC+7"`
+		}
+		b.reply(event, reply)
+		return "", false
+	}
+	return loc.String(), true
+}
+
+// replyTimezoneChoices asks to choose one of the timezones of place, as
+// buttons when there are few enough of them.
+func (b *LineBot) replyTimezoneChoices(event linebot.Event, place string, zones []string, command string) {
+	now := b.clock.Now()
+	text := fmt.Sprintf("%s has several timezones. Which one do you want?", place)
+	var labels, commands []string
+	for _, zone := range zones {
+		loc, err := util.LoadLocation(zone)
+		if err != nil {
+			continue
+		}
+		city := strings.Replace(zone[strings.LastIndex(zone, "/")+1:], "_", " ", -1)
+		labels = append(labels, fmt.Sprintf("%s (%s)", city, util.FormatUTCOffset(loc, now)))
+		commands = append(commands, fmt.Sprintf("@cpbot %s %s", command, zone))
+	}
+
+	if len(commands) > lineMaxTemplateActions {
+		reply := fmt.Sprintf("%s has several timezones. Set one of them with:\n", place)
+		for i := range commands {
+			reply += fmt.Sprintf("\n%s -> %s", commands[i], labels[i])
+		}
+		b.reply(event, reply)
+		return
+	}
+
+	var actions []linebot.TemplateAction
+	for i := range commands {
+		actions = append(actions, linebot.NewMessageTemplateAction(truncateLabel(labels[i]), commands[i]))
+	}
+	template := linebot.NewButtonsTemplate("", "", text, actions...)
+	altText := text + "\n\n" + strings.Join(commands, "\n")
+	b.replyMessages(event, linebot.NewTemplateMessage(altText, template))
+}
+
+// truncateLabel cuts label to the length Line allows for action labels, which
+// counts characters rather than bytes.
+func truncateLabel(label string) string {
+	runes := []rune(label)
+	if len(runes) > lineMaxActionLabelLength {
+		return string(runes[:lineMaxActionLabelLength])
+	}
+	return label
+}
+
 func (b *LineBot) actionGetTimezone(event linebot.Event, args ...string) {
 	user := util.LineEventSourceToString(event.Source)
 	tz, err := b.repo.GetRawTimezone(user)
 	if err != nil {
-		b.log("Error getting timezone for (%s): %s", user, err.Error())
+		b.eventLog(event).WithError(err).Error("Error getting timezone")
 		b.reply(event, "Error getting timezone, please try again in a few moments")
 		return
 	}
@@ -331,119 +828,684 @@ func (b *LineBot) actionGetTimezone(event linebot.Event, args ...string) {
 	b.reply(event, reply)
 }
 
+func (b *LineBot) actionGetMyTimezone(event linebot.Event, args ...string) {
+	user := util.LineEventSenderToString(event.Source)
+	tz, err := b.repo.GetRawMyTimezone(user)
+	if user == "" || err != nil {
+		b.reply(event, "Your own timezone has not been set, so times are shown in the timezone of this chat. Set it with the following command:\n\n@cpbot set my timezone Asia/Jakarta")
+		return
+	}
+
+	reply := fmt.Sprintf("Your own timezone is currently set to %s", tz)
+	b.reply(event, reply)
+}
+
+// timezoneFor returns the timezone to show times in to whoever triggered
+// event: their own timezone if they have set it, or else the chat's.
+func (b *LineBot) timezoneFor(event linebot.Event) *time.Location {
+	if sender := util.LineEventSenderToString(event.Source); sender != "" {
+		if tz, err := b.repo.GetMyTimezone(sender); err == nil {
+			return tz
+		}
+	}
+	tz, _ := b.repo.GetTimezone(util.LineEventSourceToString(event.Source))
+	return tz
+}
+
+func (b *LineBot) actionShowSettings(event linebot.Event, args ...string) {
+	user := util.LineEventSourceToString(event.Source)
+	settings, err := b.repo.GetSettings(user)
+	if err != nil {
+		b.eventLog(event).WithError(err).Error("Error getting settings")
+		b.reply(event, "Error getting settings, please try again in a few moments")
+		return
+	}
+
+	reply := "Settings of this chat:\n"
+
+	tz := settings.Timezone
+	if tz == "" {
+		tz = "UTC"
+	}
+	reply += fmt.Sprintf("\nTimezone: %s", tz)
+
+	if daily, err := b.getDaily(user); err != nil || len(daily) == 0 {
+		reply += "\nDaily reminder: off"
+	} else {
+		reply += fmt.Sprintf("\nDaily reminder: %s, covering contests in %s", strings.Join(daily, ", "), describeDailyWindow(settings.DailyWindow))
+	}
+
+	if b.dailySkipEmpty(user) {
+		reply += "\nSkip empty daily reminder: on"
+	} else {
+		reply += "\nSkip empty daily reminder: off"
+	}
+
+	if settings.Quiet == "" {
+		reply += "\nQuiet hours: off"
+	} else {
+		reply += fmt.Sprintf("\nQuiet hours: %s", strings.Replace(settings.Quiet, "-", " to ", 1))
+	}
+
+	if event.Source.Type != linebot.EventSourceTypeUser {
+		if admins, err := b.repo.GetAdmins(user); err == nil {
+			reply += fmt.Sprintf("\nAdmins: %d", len(admins))
+		}
+	}
+	if sender := util.LineEventSenderToString(event.Source); sender != "" {
+		if mytz, err := b.repo.GetRawMyTimezone(sender); err == nil {
+			reply += fmt.Sprintf("\nYour own timezone: %s", mytz)
+		}
+	}
+
+	b.reply(event, reply)
+}
+
+func (b *LineBot) actionReset(event linebot.Event, args ...string) {
+	text := "Reset all settings of this chat to their defaults? Daily reminders will be set back to the default time"
+	template := linebot.NewConfirmTemplate(text,
+		linebot.NewMessageTemplateAction("Reset", "@cpbot reset confirm"),
+		linebot.NewMessageTemplateAction("Cancel", "@cpbot reset cancel"),
+	)
+	altText := text + ". To confirm, send:\n\n@cpbot reset confirm"
+	b.replyMessages(event, linebot.NewTemplateMessage(altText, template))
+}
+
+func (b *LineBot) actionResetConfirm(event linebot.Event, args ...string) {
+	user := util.LineEventSourceToString(event.Source)
+	b.removeAllDaily(user)
+	if _, err := b.repo.ResetSettings(user); err != nil {
+		b.eventLog(event).WithError(err).Error("Error resetting settings")
+		b.reply(event, "Error resetting settings, please try again in a few moments")
+		return
+	}
+	// Quiet hours are gone, so are the messages held back by them
+	if _, err := b.repo.ClearDeferred(user); err != nil {
+		b.eventLog(event).WithError(err).Error("Error clearing deferred messages")
+	}
+	b.scheduler.cancel("deferred:" + user)
+	b.repo.SetTimezone(user, lineDefaultTimezone)
+	tz, _ := b.repo.GetTimezone(user)
+	b.setDefaultDaily(user, tz)
+	b.reply(event, `Settings have been reset to their defaults. Type "@cpbot settings" to see them`)
+}
+
+func (b *LineBot) actionResetCancel(event linebot.Event, args ...string) {
+	b.reply(event, "Settings are not reset")
+}
+
+func (b *LineBot) actionExport(event linebot.Event, args ...string) {
+	user := util.LineEventSourceToString(event.Source)
+	exported, err := b.exportSettings(user)
+	if err != nil {
+		b.eventLog(event).WithError(err).Error("Error getting settings")
+		b.reply(event, "Error exporting settings, please try again in a few moments")
+		return
+	}
+	code, err := encodeSettings(exported)
+	if err != nil {
+		b.eventLog(event).WithError(err).Error("Error encoding settings")
+		b.reply(event, "Error exporting settings, please try again in a few moments")
+		return
+	}
+	b.reply(event, "Send the following command in another chat to copy the settings of this chat there:", "@cpbot import "+code)
+}
+
+func (b *LineBot) actionImport(event linebot.Event, args ...string) {
+	if args[1] == "" {
+		b.reply(event, `Settings code is required for "import" command. Get one with "@cpbot export" in the chat you want to copy settings from`)
+		return
+	}
+	settings, err := decodeSettings(args[1], b.maxDuration())
+	if err != nil {
+		reply := fmt.Sprintf("%s. Settings are not changed", err.Error())
+		b.reply(event, reply)
+		return
+	}
+	if len(settings.Daily) > lineMaxDailyTimes {
+		reply := fmt.Sprintf("At most %d daily reminder times are allowed. Settings are not changed", lineMaxDailyTimes)
+		b.reply(event, reply)
+		return
+	}
+
+	user := util.LineEventSourceToString(event.Source)
+	if err = b.importSettings(user, settings); err != nil {
+		b.eventLog(event).WithError(err).Error("Error importing settings")
+		b.reply(event, "Error importing settings, please try again in a few moments")
+		return
+	}
+	b.reply(event, `Settings have been imported. Type "@cpbot settings" to see them`)
+}
+
+func (b *LineBot) exportSettings(user string) (exportedSettings, error) {
+	var exported exportedSettings
+	settings, err := b.repo.GetSettings(user)
+	if err != nil {
+		return exported, err
+	}
+	daily, err := b.getDaily(user)
+	if err != nil {
+		return exported, err
+	}
+
+	exported = exportedSettings{
+		Timezone:    settings.Timezone,
+		Daily:       daily,
+		DailyWindow: settings.DailyWindow,
+		Quiet:       settings.Quiet,
+	}
+	if skip, err := strconv.ParseBool(settings.DailySkipEmpty); err == nil {
+		exported.DailySkipEmpty = &skip
+	}
+	return exported, nil
+}
+
+// importSettings replaces all settings of user with settings, which must
+// have been validated.
+func (b *LineBot) importSettings(user string, settings exportedSettings) error {
+	b.removeAllDaily(user)
+	if _, err := b.repo.ResetSettings(user); err != nil {
+		return err
+	}
+	// Quiet hours are replaced, so are the messages held back by them
+	if _, err := b.repo.ClearDeferred(user); err != nil {
+		return err
+	}
+	b.scheduler.cancel("deferred:" + user)
+	if settings.Timezone == "" {
+		settings.Timezone = lineDefaultTimezone
+	}
+	return b.updateSettings(user, settings)
+}
+
+// updateSettings changes the settings of user that are set in settings,
+// which must have been validated. Daily reminder times are replaced if
+// settings has them, even if there are none.
+func (b *LineBot) updateSettings(user string, settings exportedSettings) error {
+	if settings.Timezone != "" {
+		if _, err := b.repo.SetTimezone(user, settings.Timezone); err != nil {
+			return err
+		}
+		b.rescheduleDaily(user)
+	}
+	if settings.DailyWindow != "" {
+		if _, err := b.repo.SetDailyWindow(user, settings.DailyWindow); err != nil {
+			return err
+		}
+	}
+	if settings.DailySkipEmpty != nil {
+		if _, err := b.repo.SetDailySkipEmpty(user, *settings.DailySkipEmpty); err != nil {
+			return err
+		}
+	}
+	if settings.Quiet != "" {
+		if _, err := b.repo.SetQuiet(user, settings.Quiet); err != nil {
+			return err
+		}
+	}
+	if settings.Daily != nil {
+		b.removeAllDaily(user)
+		for _, daily := range settings.Daily {
+			t, _ := util.ParseTime(daily)
+			b.addDaily(user, t)
+		}
+	}
+	return nil
+}
+
+func (b *LineBot) actionListAdmins(event linebot.Event, args ...string) {
+	if event.Source.Type == linebot.EventSourceTypeUser {
+		b.reply(event, "Admins are only needed in groups. You can change all settings of this chat")
+		return
+	}
+	chat := util.LineEventSourceToString(event.Source)
+	admins, err := b.repo.GetAdmins(chat)
+	if err != nil {
+		b.eventLog(event).WithError(err).Error("Error getting admins")
+		b.reply(event, "Error getting admins, please try again in a few moments")
+		return
+	}
+	if len(admins) == 0 {
+		b.reply(event, "This group has no admin yet. The first member to change a setting becomes an admin")
+		return
+	}
+	b.reply(event, "Admins of this group:\n"+strings.Join(admins, "\n"))
+}
+
+func (b *LineBot) actionAddAdmin(event linebot.Event, args ...string) {
+	if event.Source.Type == linebot.EventSourceTypeUser {
+		b.reply(event, "Admins are only needed in groups")
+		return
+	}
+	userID := args[1]
+	if userID == "" {
+		b.reply(event, `User ID is required for "admin add" command. Members can find theirs with "@cpbot whoami". Example:
+
+@cpbot admin add U1234567890abcdef1234567890abcdef`)
+		return
+	}
+	chat := util.LineEventSourceToString(event.Source)
+	if _, err := b.repo.AddAdmin(chat, userID); err != nil {
+		b.eventLog(event).WithError(err).Error("Error adding admin")
+		b.reply(event, "Error adding admin, please try again in a few moments")
+		return
+	}
+	reply := fmt.Sprintf("%s is now an admin of this group", userID)
+	b.reply(event, reply)
+}
+
+func (b *LineBot) actionRemoveAdmin(event linebot.Event, args ...string) {
+	if event.Source.Type == linebot.EventSourceTypeUser {
+		b.reply(event, "Admins are only needed in groups")
+		return
+	}
+	userID := args[1]
+	if userID == "" {
+		b.reply(event, `User ID is required for "admin remove" command. Example:
+
+@cpbot admin remove U1234567890abcdef1234567890abcdef`)
+		return
+	}
+	chat := util.LineEventSourceToString(event.Source)
+	removed, err := b.repo.RemoveAdmin(chat, userID)
+	if err != nil {
+		b.eventLog(event).WithError(err).Error("Error removing admin")
+		b.reply(event, "Error removing admin, please try again in a few moments")
+		return
+	}
+	if !removed {
+		reply := fmt.Sprintf("%s is not an admin of this group, or is its last admin", userID)
+		b.reply(event, reply)
+		return
+	}
+	reply := fmt.Sprintf("%s is no longer an admin of this group", userID)
+	b.reply(event, reply)
+}
+
+func (b *LineBot) actionWhoAmI(event linebot.Event, args ...string) {
+	if event.Source.UserID == "" {
+		b.reply(event, "Sorry, I can't tell who you are. Add me as a friend first")
+		return
+	}
+	reply := fmt.Sprintf("Your user ID is %s", event.Source.UserID)
+	b.reply(event, reply)
+}
+
 func (b *LineBot) actionUnknown(event linebot.Event, args ...string) {
 	reply := fmt.Sprintf(`%s is unknown command. Type "@cpbot help" for the complete list of commands.`, args[1])
 	b.reply(event, reply)
 }
 
-func (b *LineBot) StartDailyJob(duration time.Duration) {
-	if b.dailyTicker != nil {
-		b.log("An attempt to start daily job, but the job has already started")
+// StartDailyJob starts scheduling daily reminders every duration. Reminders
+// missed within grace before now (e.g. while the bot was down) are delivered
+// right away, unless they have been delivered before.
+func (b *LineBot) StartDailyJob(duration, grace time.Duration) {
+	if b.scheduler.started() {
+		dailyLog.Warn("An attempt to start daily job, but the job has already started")
 		return
 	}
-	b.dailyPeriod = duration
-	b.dailyTicker = time.NewTicker(b.dailyPeriod)
 
-	b.dailyJob(time.Now())
-	go func() {
-		for t := range b.dailyTicker.C {
-			b.dailyJob(t)
-		}
-	}()
+	b.dailyGrace = grace
+	b.scheduler.start(duration, b.dailyJob)
+}
+
+// StopDailyJob stops the daily job, and cancels every scheduled reminder.
+func (b *LineBot) StopDailyJob() {
+	b.scheduler.stop()
+}
+
+// Shutdown stops the daily job and processing webhook events, and waits for
+// queued events and reminders that are being delivered until ctx is done.
+// Events still queued then are lost. Reminders whose delivery is cut off
+// stay claimed until lineDailyClaimTTL passes, after which another instance
+// delivers them if they are still within the grace period.
+func (b *LineBot) Shutdown(ctx context.Context) error {
+	b.StopDailyJob()
+	b.queue.close()
+	lineLog.Info("Stopped daily job and webhook queue, waiting for work in progress")
+	if err := b.queue.wait(ctx); err != nil {
+		lineLog.WithError(err).Warnf("%d queued events were not processed in time", b.queue.len())
+		return err
+	}
+	if err := b.scheduler.wait(ctx); err != nil {
+		dailyLog.WithError(err).Warn("Deliveries in progress did not finish in time")
+		return err
+	}
+	lineLog.Info("Work in progress has finished")
+	return nil
 }
 
 func (b *LineBot) dailyJob(now time.Time) {
-	b.log("[DAILY] Start job")
-	b.dailyNext = now.Add(b.dailyPeriod)
+	dailyLog.Info("Start job")
+	// Timers of the previous period are kept until this one can be
+	// scheduled, so that they still fire if the repository is unreachable
+	next, mark := b.scheduler.extend(now)
 
-	userTimes, err := b.repo.GetDailyWithin(now, b.dailyNext)
+	userTimes, err := b.daily.GetDailyUntil(next)
+	if err != nil {
+		dailyLog.WithError(err).Error("Error getting daily until")
+		return
+	}
+	userDues, err := b.daily.GetDeferredUntil(next)
 	if err != nil {
-		b.log("[DAILY] Error getting daily within: %s", err.Error())
+		quietLog.WithError(err).Error("Error getting deferred until")
 		return
 	}
+	b.scheduler.cancelBefore(mark)
 
-	b.log("[DAILY] Schedule for the following users: %v", userTimes)
+	dailyLog.Infof("Scheduling %d reminders", len(userTimes))
 
-	b.dailyTimer = make(map[string]*time.Timer)
 	for _, userTime := range userTimes {
-		tz, _ := b.repo.GetTimezone(userTime.User)
-		next := util.NextTime(userTime.Time)
-		b.dailyTimer[userTime.User] = time.AfterFunc(next.Sub(time.Now()), b.dailyReminderFunc(userTime.User, tz))
+		tz, _ := b.daily.GetTimezone(userTime.User)
+		occurrence := userTime.Next
+		// Overdue occurrences within the grace period are delivered right
+		// away, older ones are skipped
+		if now.Sub(occurrence) > b.dailyGrace {
+			occurrence = util.NextTime(now, userTime.Time, tz)
+			b.daily.SetDailyNext(userTime.User, userTime.Time, occurrence)
+		} else if occurrence.Before(now) {
+			logging.WithChat(dailyLog, userTime.User).Infof("Catching up missed reminder at %s", occurrence)
+		}
+		b.scheduleDaily(userTime.User, userTime.Time, occurrence, tz)
+	}
+
+	for _, userDue := range userDues {
+		b.scheduleDeferred(userDue.User, time.Unix(userDue.Due, 0))
 	}
 }
 
-func (b *LineBot) dailyStarted() bool {
-	return b.dailyTicker != nil
+func dailyTimerKey(user string, t int) string {
+	return fmt.Sprintf("daily:%s@%d", user, t)
 }
 
+func (b *LineBot) scheduleDaily(user string, t int, occurrence time.Time, tz *time.Location) {
+	if b.scheduler.schedule(dailyTimerKey(user, t), occurrence, b.dailyReminderFunc(user, t, occurrence, tz)) {
+		dailyRemindersScheduledTotal.Inc()
+	}
+}
+
+func (b *LineBot) unscheduleDaily(user string, t int) {
+	b.scheduler.cancel(dailyTimerKey(user, t))
+}
+
+// updateDaily replaces all daily reminders of user with a single one at t.
 func (b *LineBot) updateDaily(user string, t int) {
+	b.removeAllDaily(user)
+	b.addDaily(user, t)
+}
+
+func (b *LineBot) addDaily(user string, t int) {
 	tz, _ := b.repo.GetTimezone(user)
+	now := b.clock.Now()
+	next := util.NextTime(now, t, tz)
 
-	_, err := b.repo.AddDaily(user, t)
+	_, err := b.repo.AddDaily(user, t, next, now)
 	if err != nil {
-		b.log("[DAILY] Error adding to repo (%s, %d): %s", user, t, err.Error())
+		logging.WithChat(dailyLog, user).WithError(err).Errorf("Error adding daily at %s", util.FormatTimeOfDay(t))
 	}
 
-	if !b.dailyStarted() {
-		return
-	}
+	b.scheduleDaily(user, t, next, tz)
+}
 
-	if t, ok := b.dailyTimer[user]; ok {
-		t.Stop()
-		delete(b.dailyTimer, user)
+// rescheduleDaily recomputes the next occurrences of all daily reminders of
+// user, e.g. after the user's timezone changes.
+func (b *LineBot) rescheduleDaily(user string) {
+	times, err := b.repo.GetDaily(user)
+	if err != nil {
+		logging.WithChat(dailyLog, user).WithError(err).Error("Error getting daily")
+		return
 	}
-
-	next := util.NextTime(t)
-	if next.Before(b.dailyNext) {
-		b.dailyTimer[user] = time.AfterFunc(next.Sub(time.Now()), b.dailyReminderFunc(user, tz))
+	tz, _ := b.repo.GetTimezone(user)
+	now := b.clock.Now()
+	for _, t := range times {
+		next := util.NextTime(now, t, tz)
+		if _, err := b.repo.SetDailyNext(user, t, next); err != nil {
+			logging.WithChat(dailyLog, user).WithError(err).Errorf("Error rescheduling daily at %s", util.FormatTimeOfDay(t))
+		}
+		b.unscheduleDaily(user, t)
+		b.scheduleDaily(user, t, next, tz)
 	}
 }
 
-func (b *LineBot) removeDaily(user string) {
-	_, err := b.repo.RemoveDaily(user)
+// removeDaily removes the daily reminder of user at t, and reports whether
+// such reminder existed.
+func (b *LineBot) removeDaily(user string, t int) bool {
+	removed, err := b.repo.RemoveDaily(user, t)
 	if err != nil {
-		b.log("[DAILY] Error removing from repo (%s): %s", user, err.Error())
+		logging.WithChat(dailyLog, user).WithError(err).Errorf("Error removing daily at %s", util.FormatTimeOfDay(t))
+		return false
 	}
 
-	if !b.dailyStarted() {
-		return
+	b.unscheduleDaily(user, t)
+	return removed
+}
+
+func (b *LineBot) removeAllDaily(user string) {
+	times, _ := b.repo.GetDaily(user)
+	_, err := b.repo.RemoveAllDaily(user)
+	if err != nil {
+		logging.WithChat(dailyLog, user).WithError(err).Error("Error removing all daily")
 	}
 
-	if t, ok := b.dailyTimer[user]; ok {
-		t.Stop()
-		delete(b.dailyTimer, user)
+	for _, t := range times {
+		b.unscheduleDaily(user, t)
 	}
 }
 
-func (b *LineBot) getDaily(user string) (string, error) {
+func (b *LineBot) getDaily(user string) ([]string, error) {
 	daily, err := b.repo.GetDaily(user)
 	if err != nil {
-		b.log("[DAILY] Error getting daily (%s): %s", user, err.Error())
-		return "", err
+		logging.WithChat(dailyLog, user).WithError(err).Error("Error getting daily")
+		return nil, err
 	}
-	tz, _ := b.repo.GetTimezone(user)
-	t := util.NextTime(daily).In(tz)
-	if t.Second() == 0 {
-		return t.Format("15:04"), nil
-	} else {
-		return t.Format("15:04:05"), nil
+	var res []string
+	for _, t := range daily {
+		res = append(res, util.FormatTimeOfDay(t))
 	}
+	return res, nil
 }
 
-func (b *LineBot) dailyReminderFunc(user string, tz *time.Location) func() {
+// dailyReminderFunc returns a function that delivers the occurrence of the
+// reminder of user at t, at most once. The occurrence only counts as
+// delivered, and the reminder only moves on to its next occurrence, once it
+// has been pushed or deferred. An occurrence that fails is left as is, to be
+// retried by the next run of the daily job within the grace period.
+func (b *LineBot) dailyReminderFunc(user string, t int, occurrence time.Time, tz *time.Location) func() {
 	return func() {
-		messages, err := generate24HUpcomingContestsMessage(b.clistService, tz, lineMaxMessageLength)
+		log := logging.WithChat(dailyLog, user)
+		ok, err := b.daily.ClaimDaily(user, t, occurrence, lineDailyClaimTTL)
 		if err != nil {
-			// TODO: retry mechanism
-			b.log("[DAILY] Error generating message: %s", err.Error())
+			log.WithError(err).Errorf("Error claiming reminder at %s", occurrence)
+			return
+		}
+		if !ok {
+			log.Infof("Reminder at %s has been delivered, or is being delivered", occurrence)
 			return
 		}
 
-		eventSource, err := util.StringToLineEventSource(user)
+		if err := b.deliverDaily(user, tz); err != nil {
+			if err := b.daily.ReleaseDaily(user, t, occurrence); err != nil {
+				log.WithError(err).Errorf("Error releasing reminder at %s", occurrence)
+			}
+			return
+		}
+		next := util.NextTime(occurrence.Add(time.Second), t, tz)
+		if err := b.daily.CompleteDaily(user, t, occurrence, next); err != nil {
+			log.WithError(err).Errorf("Error marking reminder at %s as delivered", occurrence)
+		}
+	}
+}
+
+// dailyDeferred stands for a daily reminder among the deferred messages of a
+// chat. The reminder is generated when it is delivered, so that it lists the
+// contests upcoming by the end of the quiet hours.
+const dailyDeferred = "\x00daily"
+
+// deliverDaily pushes the daily reminder of user, or defers it until the
+// quiet hours of user end. It returns an error if the reminder should be
+// retried.
+func (b *LineBot) deliverDaily(user string, tz *time.Location) error {
+	if quiet, end := b.quietUntil(user, b.clock.Now()); quiet {
+		logging.WithChat(quietLog, user).Infof("Deferring reminder until %s", end)
+		if _, err := b.daily.DeferMessages(user, end, dailyDeferred); err != nil {
+			logging.WithChat(quietLog, user).WithError(err).Error("Error deferring reminder")
+			return err
+		}
+		b.scheduleDeferred(user, end)
+		return nil
+	}
+
+	messages, priority, err := b.dailyMessages(user, tz)
+	if err != nil {
+		return err
+	}
+	if len(messages) == 0 {
+		return nil
+	}
+	eventSource, err := util.StringToLineEventSource(user)
+	if err != nil {
+		logging.WithChat(lineLog, user).WithError(err).Warn("Found invalid user")
+		return err
+	}
+	switch err := b.push(eventSource, priority, messages...); err {
+	case nil:
+		dailyRemindersDeliveredTotal.Inc()
+		return nil
+	case errQuotaLow, errQuotaExhausted:
+		// Retrying would not get it through before the quota resets
+		return nil
+	default:
+		return err
+	}
+}
+
+// dailyMessages generates the daily reminder of user as of now, and the
+// priority to push it with. There are no messages if it is skipped.
+func (b *LineBot) dailyMessages(user string, tz *time.Location) ([]string, pushPriority, error) {
+	window, _ := b.daily.GetDailyWindow(user)
+	from, to, header := dailyWindowRange(window, b.maxDuration(), b.clock.Now(), tz)
+	contests, err := b.clistService.GetContestsStartingBetween(from, to)
+	if err != nil {
+		logging.WithChat(dailyLog, user).WithError(err).Error("Error generating message")
+		return nil, pushLow, err
+	}
+
+	if len(contests) == 0 && b.dailySkipEmpty(user) {
+		logging.WithChat(dailyLog, user).Info("Skipping empty reminder")
+		dailyRemindersSkippedTotal.Inc()
+		return nil, pushLow, nil
+	}
+
+	// Empty reminders are the first to go when running low on quota
+	priority := pushNormal
+	if len(contests) == 0 {
+		priority = pushLow
+	}
+	return formatUpcomingContestsMessage(contests, tz, header, b.config.MaxMessageLength), priority, nil
+}
+
+// dailySkipEmpty reports whether daily reminders without any contest should
+// not be sent to user, falling back to the configured default if unset.
+func (b *LineBot) dailySkipEmpty(user string) bool {
+	skip, err := b.daily.GetDailySkipEmpty(user)
+	if err != nil {
+		return b.config.DailySkipEmpty
+	}
+	return skip
+}
+
+// quietUntil reports whether now falls within the quiet hours of user,
+// evaluated in the user's timezone, and when the quiet hours end.
+func (b *LineBot) quietUntil(user string, now time.Time) (bool, time.Time) {
+	quiet, err := b.daily.GetQuiet(user)
+	if err != nil {
+		return false, time.Time{}
+	}
+	from, to, err := util.ParseTimeRange(quiet)
+	if err != nil {
+		logging.WithChat(quietLog, user).Warnf("Found invalid quiet hours: %s", quiet)
+		return false, time.Time{}
+	}
+	tz, _ := b.daily.GetTimezone(user)
+	return util.InTimeRange(now.In(tz), from, to)
+}
+
+// scheduleDeferred delivers the deferred messages of user at due. Every
+// instance schedules it, but messages are popped atomically, so only one of
+// them gets to push them.
+func (b *LineBot) scheduleDeferred(user string, due time.Time) {
+	b.scheduler.schedule("deferred:"+user, due, func() {
+		b.deliverDeferred(user, due)
+	})
+}
+
+// deliverDeferred pushes the deferred messages of user, which were due at
+// due, generating deferred daily reminders as of now. If the push fails, they
+// are deferred again, to be retried by the next run of the daily job within
+// the grace period.
+func (b *LineBot) deliverDeferred(user string, due time.Time) {
+	deferred, err := b.daily.PopDeferred(user)
+	if err != nil {
+		logging.WithChat(quietLog, user).WithError(err).Error("Error getting deferred messages")
+		return
+	}
+	if len(deferred) == 0 {
+		return
+	}
+
+	eventSource, err := util.StringToLineEventSource(user)
+	if err != nil {
+		logging.WithChat(quietLog, user).WithError(err).Warn("Found invalid user")
+		return
+	}
+	var messages []string
+	priority, daily := pushLow, false
+	for _, message := range deferred {
+		if message != dailyDeferred {
+			messages = append(messages, message)
+			priority = pushNormal
+			continue
+		}
+		if daily {
+			// Reminders deferred by the same quiet hours are sent as one
+			continue
+		}
+		tz, _ := b.daily.GetTimezone(user)
+		reminder, p, err := b.dailyMessages(user, tz)
 		if err != nil {
-			b.log("[DAILY] found invalid user [%s]: %s", user, err.Error())
+			b.deferAgain(user, due, deferred)
 			return
 		}
-		b.push(util.LineEventSourceToReplyString(eventSource), messages...)
+		messages = append(messages, reminder...)
+		if p > priority {
+			priority = p
+		}
+		daily = true
+	}
+	if len(messages) == 0 {
+		return
+	}
+
+	logging.WithChat(quietLog, user).Infof("Delivering %d deferred messages", len(messages))
+	switch err := b.push(eventSource, priority, messages...); err {
+	case nil:
+		if daily {
+			dailyRemindersDeliveredTotal.Inc()
+		}
+	case errQuotaLow, errQuotaExhausted:
+	default:
+		b.deferAgain(user, due, deferred)
+	}
+}
+
+// deferAgain defers messages of user that could not be delivered, keeping
+// them due at due, unless that is past the grace period.
+func (b *LineBot) deferAgain(user string, due time.Time, messages []string) {
+	if b.clock.Now().Sub(due) > b.dailyGrace {
+		logging.WithChat(quietLog, user).Warnf("Dropping %d deferred messages due at %s", len(messages), due)
+		return
+	}
+	if _, err := b.daily.DeferMessages(user, due, messages...); err != nil {
+		logging.WithChat(quietLog, user).WithError(err).Error("Error deferring messages again")
 	}
 }
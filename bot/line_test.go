@@ -0,0 +1,47 @@
+package bot
+
+import (
+	"testing"
+	"unicode/utf8"
+
+	"github.com/azaky/cpbot/logging"
+	"github.com/line/line-bot-sdk-go/linebot"
+)
+
+func TestTruncateLabel(t *testing.T) {
+	tests := []struct {
+		label string
+		want  string
+	}{
+		{"Asia/Jakarta", "Asia/Jakarta"},
+		{"America/Argentina/Buenos_Aires", "America/Argentina/Bu"},
+		{"São Paulo (UTC-03:00)", "São Paulo (UTC-03:00"},
+		{"東京都 (UTC+09:00) Asia/Tokyo", "東京都 (UTC+09:00) Asia"},
+	}
+	for _, test := range tests {
+		got := truncateLabel(test.label)
+		if got != test.want {
+			t.Errorf("truncateLabel(%q) = %q, want %q", test.label, got, test.want)
+		}
+		if !utf8.ValidString(got) {
+			t.Errorf("truncateLabel(%q) = %q is not valid UTF-8", test.label, got)
+		}
+	}
+}
+
+func TestEventLogCarriesRequestID(t *testing.T) {
+	b := &LineBot{}
+	event := linebot.Event{
+		Type:       linebot.EventTypeMessage,
+		ReplyToken: "token",
+		Source:     &linebot.EventSource{Type: linebot.EventSourceTypeUser, UserID: "U1"},
+	}
+	if _, ok := b.eventLog(event).Data[logging.FieldRequestID]; ok {
+		t.Error("Got a request id for an event that is not being processed")
+	}
+
+	b.requestIDs.Store(lineEventID(event), "request")
+	if got := b.eventLog(event).Data[logging.FieldRequestID]; got != "request" {
+		t.Errorf("Got request id %v, want request", got)
+	}
+}
@@ -0,0 +1,139 @@
+package bot
+
+import (
+	"github.com/azaky/cpbot/repository"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	metricSuccess = "success"
+	metricFailure = "failure"
+)
+
+// Metrics of the Line bot, exposed at /metrics. Their names are part of the
+// interface of cpbot, do not change them.
+var (
+	// cpbot_line_events_received_total{type="message|follow|join|..."}
+	lineEventsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "cpbot_line_events_received_total",
+		Help: "Webhook events received from Line, by event type.",
+	}, []string{"type"})
+	// cpbot_line_commands_total{command="set_daily|get_timezone|..."}, see
+	// the names given to registerTextPattern
+	lineCommandsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "cpbot_line_commands_total",
+		Help: "Text messages matched by each command.",
+	}, []string{"command"})
+	// cpbot_line_pushes_total{result="success|failure"}
+	linePushesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "cpbot_line_pushes_total",
+		Help: "Push requests to Line, each of up to 5 messages.",
+	}, []string{"result"})
+	// cpbot_line_replies_total{result="success|failure"}
+	lineRepliesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "cpbot_line_replies_total",
+		Help: "Reply requests to Line.",
+	}, []string{"result"})
+	// cpbot_line_commands_limited_total counts commands ignored because of
+	// the rate limit of their chat or user, by scope: chat or user
+	lineCommandsLimitedTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "cpbot_line_commands_limited_total",
+		Help: "Commands ignored because of rate limits, by scope.",
+	}, []string{"scope"})
+	// cpbot_line_pushes_dropped_total counts pushes not sent to save the
+	// monthly quota, by reason: quota_low or quota_exhausted
+	linePushesDroppedTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "cpbot_line_pushes_dropped_total",
+		Help: "Pushes not sent to save the monthly push quota, by reason.",
+	}, []string{"reason"})
+	// cpbot_line_events_duplicate_total counts events that were not processed
+	// because they had been processed before, e.g. when Line redelivers
+	// them
+	lineEventsDuplicateTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "cpbot_line_events_duplicate_total",
+		Help: "Webhook events skipped because they had been processed before.",
+	})
+	// cpbot_line_queue_rejected_total counts events not queued because the
+	// queue of their chat was full. Line redelivers them only if webhook
+	// redelivery is enabled on the channel, otherwise they are lost.
+	lineQueueRejectedTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "cpbot_line_queue_rejected_total",
+		Help: "Webhook events rejected because the queue was full, lost unless webhook redelivery is enabled on the channel.",
+	})
+	// cpbot_line_queue_wait_seconds
+	lineQueueWaitSeconds = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "cpbot_line_queue_wait_seconds",
+		Help:    "Time webhook events wait in the queue before being processed.",
+		Buckets: prometheus.DefBuckets,
+	})
+	// cpbot_daily_reminders_scheduled_total counts timers scheduled by this
+	// instance. Every instance schedules every reminder, and a reminder is
+	// scheduled again when it is changed.
+	dailyRemindersScheduledTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "cpbot_daily_reminders_scheduled_total",
+		Help: "Daily reminder timers scheduled by this instance.",
+	})
+	// cpbot_daily_reminders_delivered_total
+	dailyRemindersDeliveredTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "cpbot_daily_reminders_delivered_total",
+		Help: "Daily reminders pushed by this instance, including those deferred by quiet hours once they are pushed.",
+	})
+	// cpbot_daily_reminders_skipped_total
+	dailyRemindersSkippedTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "cpbot_daily_reminders_skipped_total",
+		Help: "Daily reminders not sent by this instance because there was no contest.",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(
+		lineEventsTotal,
+		lineCommandsTotal,
+		linePushesTotal,
+		lineRepliesTotal,
+		lineCommandsLimitedTotal,
+		linePushesDroppedTotal,
+		lineEventsDuplicateTotal,
+		lineQueueRejectedTotal,
+		lineQueueWaitSeconds,
+		dailyRemindersScheduledTotal,
+		dailyRemindersDeliveredTotal,
+		dailyRemindersSkippedTotal,
+	)
+}
+
+// registerActiveChats registers cpbot_line_active_chats, the number of chats
+// the bot is in, as counted in repo when scraped.
+func registerActiveChats(repo *repository.Redis) {
+	registerGaugeFunc(prometheus.GaugeOpts{
+		Name: "cpbot_line_active_chats",
+		Help: "Chats (users, groups and rooms) the bot is in.",
+	}, func() float64 {
+		n, err := repo.CountUsers()
+		if err != nil {
+			return -1
+		}
+		return float64(n)
+	})
+}
+
+// registerQueueDepth registers cpbot_line_queue_depth, the number of
+// webhook events waiting in q.
+func registerQueueDepth(q *eventQueue) {
+	registerGaugeFunc(prometheus.GaugeOpts{
+		Name: "cpbot_line_queue_depth",
+		Help: "Webhook events waiting in the queue.",
+	}, func() float64 {
+		return float64(q.len())
+	})
+}
+
+// registerGaugeFunc registers a gauge that is computed by f when scraped. Only
+// the first one registered with the same name is kept, as gauges depend on
+// the LineBot they are registered by.
+func registerGaugeFunc(opts prometheus.GaugeOpts, f func() float64) {
+	err := prometheus.Register(prometheus.NewGaugeFunc(opts, f))
+	if _, ok := err.(prometheus.AlreadyRegisteredError); err != nil && !ok {
+		panic(err)
+	}
+}
@@ -0,0 +1,125 @@
+package bot
+
+import (
+	"context"
+	"hash/fnv"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/azaky/cpbot/logging"
+	"github.com/azaky/cpbot/util"
+	"github.com/line/line-bot-sdk-go/linebot"
+)
+
+// eventQueue processes webhook events in the background with a fixed number
+// of workers. Events of the same chat always go to the same worker, so they
+// are processed in the order they are received.
+type eventQueue struct {
+	clock  util.Clock
+	handle func(linebot.Event, string)
+	queues []chan queuedEvent
+	wg     sync.WaitGroup
+	mu     sync.RWMutex
+	closed bool
+}
+
+type queuedEvent struct {
+	event     linebot.Event
+	requestID string
+	enqueued  time.Time
+}
+
+// newEventQueue starts workers, each with a queue of up to size events, that
+// call handle for each event and the id of the webhook request it came in.
+func newEventQueue(clock util.Clock, workers, size int, handle func(linebot.Event, string)) *eventQueue {
+	q := &eventQueue{
+		clock:  clock,
+		handle: handle,
+		queues: make([]chan queuedEvent, workers),
+	}
+	for i := range q.queues {
+		q.queues[i] = make(chan queuedEvent, size)
+		q.wg.Add(1)
+		go q.work(q.queues[i])
+	}
+	return q
+}
+
+func (q *eventQueue) work(queue chan queuedEvent) {
+	defer q.wg.Done()
+	for e := range queue {
+		lineQueueWaitSeconds.Observe(q.clock.Now().Sub(e.enqueued).Seconds())
+		q.process(e)
+	}
+}
+
+// process handles e, recovering from panics so that a bad event does not
+// take down its worker, and every chat queued after it.
+func (q *eventQueue) process(e queuedEvent) {
+	defer func() {
+		if r := recover(); r != nil {
+			logging.WithChat(lineLog, util.LineEventSourceToString(e.event.Source)).
+				WithField(logging.FieldRequestID, e.requestID).
+				Errorf("Panic processing %s event: %v\n%s", e.event.Type, r, debug.Stack())
+		}
+	}()
+	q.handle(e.event, e.requestID)
+}
+
+// push queues event, which came in the webhook request requestID, and reports
+// whether it has been queued. It is not if the queue of its chat is full, or
+// the queue has been closed.
+func (q *eventQueue) push(event linebot.Event, requestID string) bool {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	if q.closed {
+		return false
+	}
+	h := fnv.New32a()
+	h.Write([]byte(util.LineEventSourceToString(event.Source)))
+	select {
+	case q.queues[h.Sum32()%uint32(len(q.queues))] <- queuedEvent{event: event, requestID: requestID, enqueued: q.clock.Now()}:
+		return true
+	default:
+		return false
+	}
+}
+
+// len returns the number of events waiting in every queue.
+func (q *eventQueue) len() int {
+	n := 0
+	for _, queue := range q.queues {
+		n += len(queue)
+	}
+	return n
+}
+
+// close stops accepting events. Events already queued are still processed,
+// use wait to wait for them.
+func (q *eventQueue) close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return
+	}
+	q.closed = true
+	for _, queue := range q.queues {
+		close(queue)
+	}
+}
+
+// wait waits until every queued event has been processed, or ctx is done.
+func (q *eventQueue) wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		q.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,188 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/azaky/cpbot/config"
+	"github.com/azaky/cpbot/util"
+	"github.com/line/line-bot-sdk-go/linebot"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func testEvent(group string, n int) linebot.Event {
+	return linebot.Event{
+		Type:       linebot.EventTypeMessage,
+		ReplyToken: fmt.Sprintf("%s:%d", group, n),
+		Source:     &linebot.EventSource{Type: linebot.EventSourceTypeGroup, GroupID: group, UserID: "U1"},
+		Message:    linebot.NewTextMessage(fmt.Sprint(n)),
+	}
+}
+
+func waitQueue(t *testing.T, q *eventQueue) {
+	t.Helper()
+	q.close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := q.wait(ctx); err != nil {
+		t.Fatalf("Queued events were not processed: %v", err)
+	}
+}
+
+func TestEventQueueKeepsOrderOfEachChat(t *testing.T) {
+	var mu sync.Mutex
+	handled := make(map[string][]string)
+	q := newEventQueue(util.NewFakeClock(time.Now()), 4, 100, func(event linebot.Event, requestID string) {
+		mu.Lock()
+		defer mu.Unlock()
+		handled[event.Source.GroupID] = append(handled[event.Source.GroupID], event.ReplyToken)
+	})
+
+	groups := []string{"C1", "C2", "C3", "C4", "C5"}
+	for n := 0; n < 20; n++ {
+		for _, group := range groups {
+			if !q.push(testEvent(group, n), "request") {
+				t.Fatalf("Event %d of %s was not queued", n, group)
+			}
+		}
+	}
+	waitQueue(t, q)
+
+	for _, group := range groups {
+		if len(handled[group]) != 20 {
+			t.Fatalf("Handled %d events of %s, want 20", len(handled[group]), group)
+		}
+		for n, token := range handled[group] {
+			if want := fmt.Sprintf("%s:%d", group, n); token != want {
+				t.Errorf("Handled %s as event %d of %s, want %s", token, n, group, want)
+				break
+			}
+		}
+	}
+}
+
+func TestEventQueueRejectsWhenFull(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	q := newEventQueue(util.NewFakeClock(time.Now()), 1, 2, func(event linebot.Event, requestID string) {
+		if event.ReplyToken == "C1:0" {
+			close(started)
+			<-release
+		}
+	})
+
+	q.push(testEvent("C1", 0), "request")
+	<-started
+	// The worker is busy, so the queue fills up
+	for n := 1; n <= 2; n++ {
+		if !q.push(testEvent("C1", n), "request") {
+			t.Fatalf("Event %d was not queued", n)
+		}
+	}
+	if q.push(testEvent("C1", 3), "request") {
+		t.Error("Event was queued in a full queue")
+	}
+	if n := q.len(); n != 2 {
+		t.Errorf("Got %d queued events, want 2", n)
+	}
+
+	close(release)
+	waitQueue(t, q)
+	if q.push(testEvent("C1", 4), "request") {
+		t.Error("Event was queued in a closed queue")
+	}
+}
+
+func TestEventQueueRecoversFromPanics(t *testing.T) {
+	var mu sync.Mutex
+	var handled []string
+	q := newEventQueue(util.NewFakeClock(time.Now()), 1, 10, func(event linebot.Event, requestID string) {
+		if event.ReplyToken == "C1:0" {
+			panic("bad event")
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		handled = append(handled, event.ReplyToken)
+	})
+
+	q.push(testEvent("C1", 0), "request")
+	q.push(testEvent("C1", 1), "request")
+	q.push(testEvent("C2", 0), "request")
+	waitQueue(t, q)
+
+	// The worker goes on with the events queued after the bad one
+	if want := []string{"C1:1", "C2:0"}; fmt.Sprint(handled) != fmt.Sprint(want) {
+		t.Errorf("Handled %v, want %v", handled, want)
+	}
+}
+
+// memoryEvents is an in-memory repository.EventStore.
+type memoryEvents struct {
+	mu        sync.Mutex
+	processed map[string]bool
+}
+
+func (e *memoryEvents) IsEventProcessed(id string) (bool, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.processed[id], nil
+}
+
+func (e *memoryEvents) MarkEventProcessed(id string, ttl time.Duration) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.processed[id] = true
+	return nil
+}
+
+func TestHandleEventSkipsDuplicates(t *testing.T) {
+	events := &memoryEvents{processed: make(map[string]bool)}
+	b := &LineBot{events: events}
+	// Postbacks are not handled, so nothing but deduplication happens
+	event := linebot.Event{
+		Type:       linebot.EventTypePostback,
+		ReplyToken: "token",
+		Source:     &linebot.EventSource{Type: linebot.EventSourceTypeUser, UserID: "U1"},
+	}
+	duplicates := testutil.ToFloat64(lineEventsDuplicateTotal)
+
+	b.handleEvent(event, "first")
+	if !events.processed[lineEventID(event)] {
+		t.Fatal("Event was not marked as processed")
+	}
+	b.handleEvent(event, "redelivery")
+	if got := testutil.ToFloat64(lineEventsDuplicateTotal) - duplicates; got != 1 {
+		t.Errorf("Got %v duplicate events, want 1", got)
+	}
+	if _, ok := b.requestIDs.Load(lineEventID(event)); ok {
+		t.Error("Request id was kept after the event was processed")
+	}
+}
+
+func TestHandleEventMarksProcessedOnlyAfterHandling(t *testing.T) {
+	events := &memoryEvents{processed: make(map[string]bool)}
+	b := &LineBot{events: events, config: config.Line{MaxInputLength: 1000}}
+	event := testEvent("C1", 0)
+	event.Message = linebot.NewTextMessage("@cpbot test")
+	handled := 0
+	b.textPatterns = []patternHandler{{
+		Name:    "test",
+		Pattern: regexp.MustCompile(`^@cpbot test$`),
+		Handler: func(event linebot.Event, args ...string) {
+			handled++
+			if processed, _ := events.IsEventProcessed(lineEventID(event)); processed {
+				t.Error("Event was marked as processed before it was handled")
+			}
+		},
+	}}
+
+	b.handleEvent(event, "first")
+	b.handleEvent(event, "redelivery")
+	if handled != 1 {
+		t.Errorf("Handled the event %d times, want 1", handled)
+	}
+}
@@ -0,0 +1,51 @@
+package bot
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/azaky/cpbot/util"
+	"github.com/line/line-bot-sdk-go/linebot"
+)
+
+// Commands are rate limited in windows of this long.
+const lineCommandRateWindow = time.Minute
+
+// allowCommand reports whether the command of event is within the rate limit
+// of its chat and of its user.
+func (b *LineBot) allowCommand(event linebot.Event) bool {
+	if !b.allowRate(event, "chat", util.LineEventSourceToString(event.Source), b.config.CommandRateChat,
+		"Whoa, slow down! Too many commands in this chat, please try again in a minute") {
+		return false
+	}
+	user := util.LineEventSenderToString(event.Source)
+	if user == "" {
+		return true
+	}
+	return b.allowRate(event, "user", user, b.config.CommandRateUser,
+		"Whoa, slow down! Too many commands from you, please try again in a minute")
+}
+
+// allowRate counts a command of id, and reports whether it is within limit.
+// The first command over limit is replied with slowDown, the rest are
+// ignored until the window ends. A limit that is not positive is unlimited.
+func (b *LineBot) allowRate(event linebot.Event, scope, id string, limit int, slowDown string) bool {
+	if limit <= 0 {
+		return true
+	}
+	n, err := b.rates.CountRate(fmt.Sprintf("command:%s:%s", scope, id), lineCommandRateWindow)
+	if err != nil {
+		// Better to answer too much than not at all
+		b.eventLog(event).WithError(err).Error("Error counting commands")
+		return true
+	}
+	if n <= limit {
+		return true
+	}
+	lineCommandsLimitedTotal.WithLabelValues(scope).Inc()
+	if n == limit+1 {
+		b.eventLog(event).Warnf("Rate limiting commands of %s, over %d per %s", scope, limit, lineCommandRateWindow)
+		b.reply(event, slowDown)
+	}
+	return false
+}
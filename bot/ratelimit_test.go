@@ -0,0 +1,138 @@
+package bot
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/azaky/cpbot/util"
+	"github.com/line/line-bot-sdk-go/linebot"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// newTestRateBot returns a LineBot limiting commands to chatRate per chat and
+// userRate per user, and the recorder of its replies, which records reply
+// tokens as recipients.
+func newTestRateBot(chatRate, userRate int) (*LineBot, *util.FakeClock, *pushRecorder) {
+	clock := util.NewFakeClock(time.Date(2026, 3, 7, 0, 0, 0, 0, time.UTC))
+	b := newTestLineBot(clock, newMemoryStore(clock), &pushRecorder{clock: clock})
+	b.config.CommandRateChat = chatRate
+	b.config.CommandRateUser = userRate
+	replies := &pushRecorder{clock: clock}
+	b.sendReply = replies.send
+	return b, clock, replies
+}
+
+func commandEvent(group, user, token string) linebot.Event {
+	return linebot.Event{
+		Type:       linebot.EventTypeMessage,
+		ReplyToken: token,
+		Source:     &linebot.EventSource{Type: linebot.EventSourceTypeGroup, GroupID: group, UserID: user},
+		Message:    linebot.NewTextMessage("@cpbot help"),
+	}
+}
+
+func TestAllowCommandRejectsOverLimit(t *testing.T) {
+	b, _, replies := newTestRateBot(3, 0)
+	limited := testutil.ToFloat64(lineCommandsLimitedTotal.WithLabelValues("chat"))
+
+	for i, want := range []bool{true, true, true, false, false} {
+		if got := b.allowCommand(commandEvent("C1", "U1", fmt.Sprintf("T%d", i))); got != want {
+			t.Errorf("Command %d allowed = %t, want %t", i, got, want)
+		}
+	}
+
+	// Only the first command over the limit is answered
+	got := replies.recorded()
+	if len(got) != 1 || got[0].To != "T3" || got[0].Text != "Whoa, slow down! Too many commands in this chat, please try again in a minute" {
+		t.Errorf("Got replies %+v, want a single slow down reply to T3", got)
+	}
+	if got := testutil.ToFloat64(lineCommandsLimitedTotal.WithLabelValues("chat")) - limited; got != 2 {
+		t.Errorf("Counted %v limited commands, want 2", got)
+	}
+	// Other chats have limits of their own
+	if !b.allowCommand(commandEvent("C2", "U1", "T5")) {
+		t.Errorf("Command of another chat was not allowed")
+	}
+}
+
+func TestAllowCommandWindowRollover(t *testing.T) {
+	b, clock, replies := newTestRateBot(2, 0)
+	b.allowCommand(commandEvent("C1", "U1", "T0"))
+	clock.Advance(30 * time.Second)
+	b.allowCommand(commandEvent("C1", "U1", "T1"))
+	if b.allowCommand(commandEvent("C1", "U1", "T2")) {
+		t.Fatalf("Command over the limit was allowed")
+	}
+
+	// The window started with the first command
+	clock.Advance(lineCommandRateWindow - 30*time.Second - time.Second)
+	if b.allowCommand(commandEvent("C1", "U1", "T3")) {
+		t.Errorf("Command over the limit was allowed before the window ended")
+	}
+	clock.Advance(time.Second)
+	for _, token := range []string{"T4", "T5"} {
+		if !b.allowCommand(commandEvent("C1", "U1", token)) {
+			t.Errorf("Command %s of a new window was not allowed", token)
+		}
+	}
+	if b.allowCommand(commandEvent("C1", "U1", "T6")) {
+		t.Errorf("Command over the limit of a new window was allowed")
+	}
+
+	// Each window gets its own slow down reply
+	got := replies.recorded()
+	if len(got) != 2 || got[0].To != "T2" || got[1].To != "T6" {
+		t.Errorf("Got replies %+v, want slow down replies to T2 and T6", got)
+	}
+}
+
+func TestAllowCommandLimitsUsersAcrossChats(t *testing.T) {
+	b, _, replies := newTestRateBot(0, 2)
+	b.allowCommand(commandEvent("C1", "U1", "T0"))
+	b.allowCommand(commandEvent("C2", "U1", "T1"))
+	if b.allowCommand(commandEvent("C3", "U1", "T2")) {
+		t.Errorf("Command over the limit of the user was allowed")
+	}
+	if !b.allowCommand(commandEvent("C3", "U2", "T3")) {
+		t.Errorf("Command of another user was not allowed")
+	}
+	// Commands without a known sender are only limited by chat
+	if !b.allowCommand(commandEvent("C1", "", "T4")) {
+		t.Errorf("Command without a sender was not allowed")
+	}
+
+	got := replies.recorded()
+	if len(got) != 1 || got[0].Text != "Whoa, slow down! Too many commands from you, please try again in a minute" {
+		t.Errorf("Got replies %+v, want a single slow down reply to the user", got)
+	}
+}
+
+func TestInDurationCap(t *testing.T) {
+	b, _, replies := newTestRateBot(0, 0)
+	b.config.MaxInDuration = 48 * 3600
+	tests := []struct {
+		duration string
+		want     string
+	}{
+		{"49h", "Duration must be at most 48h"},
+		{"48h0m1s", "Duration must be at most 48h"},
+		{"3000m", "Duration must be at most 48h"},
+		{"0h", "0h is not a valid duration"},
+		{"-1h", "-1h is not a valid duration"},
+		{"2d", "2d is not a valid duration"},
+	}
+	for _, test := range tests {
+		b.actionShowContestsWithin(commandEvent("C1", "U1", test.duration), "@cpbot in "+test.duration, test.duration)
+	}
+
+	got := replies.recorded()
+	if len(got) != len(tests) {
+		t.Fatalf("Got %d replies, want %d", len(got), len(tests))
+	}
+	for i, test := range tests {
+		if got[i].Text != test.want {
+			t.Errorf("Replied %q to in %s, want %q", got[i].Text, test.duration, test.want)
+		}
+	}
+}
@@ -0,0 +1,231 @@
+package bot
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/azaky/cpbot/util"
+)
+
+// scheduler runs a job periodically, and owns the timers scheduled for the
+// current period. It is safe for concurrent use, so that webhook handlers can
+// schedule and cancel timers while the job is running.
+type scheduler struct {
+	clock  util.Clock
+	mu     sync.Mutex
+	period time.Duration
+	next   time.Time
+	timers map[string]*scheduledTimer
+	// seq numbers timers in the order they are scheduled
+	seq    uint64
+	ticker util.Ticker
+	done   chan struct{}
+	// lastRun is when the job last started running
+	lastRun time.Time
+	// inflight counts runs of the job and of timers that have not returned
+	inflight sync.WaitGroup
+}
+
+type scheduledTimer struct {
+	timer util.Timer
+	at    time.Time
+	seq   uint64
+}
+
+func newScheduler(clock util.Clock) *scheduler {
+	return &scheduler{
+		clock:  clock,
+		timers: make(map[string]*scheduledTimer),
+	}
+}
+
+// start runs job right away and then every period until stop is called. It
+// returns false if the scheduler has already started.
+func (s *scheduler) start(period time.Duration, job func(now time.Time)) bool {
+	s.mu.Lock()
+	if s.ticker != nil {
+		s.mu.Unlock()
+		return false
+	}
+	s.period = period
+	s.ticker = s.clock.NewTicker(period)
+	s.done = make(chan struct{})
+	ticker, done := s.ticker, s.done
+	s.mu.Unlock()
+
+	s.run(job, s.clock.Now())
+	go func() {
+		for {
+			select {
+			case now := <-ticker.C():
+				s.run(job, now)
+			case <-done:
+				return
+			}
+		}
+	}()
+	return true
+}
+
+func (s *scheduler) run(job func(now time.Time), now time.Time) {
+	s.mu.Lock()
+	if s.ticker == nil {
+		s.mu.Unlock()
+		return
+	}
+	s.lastRun = s.clock.Now()
+	s.inflight.Add(1)
+	s.mu.Unlock()
+	defer s.inflight.Done()
+	job(now)
+}
+
+// alive reports whether the job is still running every period, that is if
+// it has started running within the last two periods. It returns when the
+// job last started running as well.
+func (s *scheduler) alive() (bool, time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ticker == nil {
+		return false, s.lastRun
+	}
+	return s.clock.Now().Sub(s.lastRun) < 2*s.period, s.lastRun
+}
+
+func (s *scheduler) started() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.ticker != nil
+}
+
+// stop stops running the job and cancels every pending timer. Runs of the
+// job and timers that have already started are not interrupted, use wait
+// to wait for them.
+func (s *scheduler) stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ticker == nil {
+		return
+	}
+	s.ticker.Stop()
+	close(s.done)
+	s.ticker = nil
+	s.next = time.Time{}
+	s.cancelAllLocked()
+}
+
+// extend starts a new period at now, keeping the timers scheduled so far. It
+// returns the end of the new period, and a mark to cancel those timers with
+// cancelBefore, once the new period has been scheduled. Timers scheduled
+// after extend, e.g. by webhook handlers, are kept.
+func (s *scheduler) extend(now time.Time) (time.Time, uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.next = now.Add(s.period)
+	return s.next, s.seq
+}
+
+// cancelBefore cancels every pending timer scheduled before mark was
+// returned by extend.
+func (s *scheduler) cancelBefore(mark uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, t := range s.timers {
+		if t.seq <= mark {
+			t.timer.Stop()
+			delete(s.timers, key)
+		}
+	}
+}
+
+// schedule runs f at the given time, replacing the timer with the same key.
+// Nothing is scheduled if at is not within the current period, as it is the
+// job of a later period that schedules it.
+func (s *scheduler) schedule(key string, at time.Time, f func()) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ticker == nil || !at.Before(s.next) {
+		return false
+	}
+	if t, ok := s.timers[key]; ok {
+		t.timer.Stop()
+	}
+	s.seq++
+	t := &scheduledTimer{at: at, seq: s.seq}
+	t.timer = s.clock.AfterFunc(at.Sub(s.clock.Now()), func() {
+		s.mu.Lock()
+		if s.timers[key] == t {
+			delete(s.timers, key)
+		}
+		if s.ticker == nil {
+			// Stopped right as the timer fired
+			s.mu.Unlock()
+			return
+		}
+		s.inflight.Add(1)
+		s.mu.Unlock()
+		defer s.inflight.Done()
+		f()
+	})
+	s.timers[key] = t
+	return true
+}
+
+// wait waits until every run of the job and every timer that has started
+// returns, or ctx is done. It should be called after stop, so that nothing
+// starts while waiting.
+func (s *scheduler) wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		s.inflight.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *scheduler) cancel(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if t, ok := s.timers[key]; ok {
+		t.timer.Stop()
+		delete(s.timers, key)
+	}
+}
+
+func (s *scheduler) cancelAllLocked() {
+	for key, t := range s.timers {
+		t.timer.Stop()
+		delete(s.timers, key)
+	}
+}
+
+// pendingTimer describes a timer that has not fired yet.
+type pendingTimer struct {
+	Key string    `json:"key"`
+	At  time.Time `json:"at"`
+}
+
+// pending returns the timers that have not fired yet, earliest first, and
+// the end of the current period.
+func (s *scheduler) pending() ([]pendingTimer, time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	res := make([]pendingTimer, 0, len(s.timers))
+	for key, t := range s.timers {
+		res = append(res, pendingTimer{Key: key, At: t.at})
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].At.Equal(res[j].At) {
+			return res[i].Key < res[j].Key
+		}
+		return res[i].At.Before(res[j].At)
+	})
+	return res, s.next
+}
@@ -0,0 +1,165 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/azaky/cpbot/util"
+)
+
+const testTimers = 100
+
+func newTestScheduler(clock util.Clock) *scheduler {
+	s := newScheduler(clock)
+	s.start(time.Hour, func(now time.Time) {
+		s.extend(now)
+	})
+	return s
+}
+
+func TestSchedulerOnlySchedulesWithinPeriod(t *testing.T) {
+	start := time.Date(2026, 3, 7, 0, 0, 0, 0, time.UTC)
+	clock := util.NewFakeClock(start)
+	s := newTestScheduler(clock)
+	defer s.stop()
+
+	var runs int32
+	inc := func() { atomic.AddInt32(&runs, 1) }
+	if !s.schedule("a", start.Add(59*time.Minute), inc) {
+		t.Error("Timer within the period was not scheduled")
+	}
+	if s.schedule("b", start.Add(time.Hour), inc) {
+		t.Error("Timer at the end of the period was scheduled")
+	}
+	// Replaces the previous timer of the same key
+	s.schedule("a", start.Add(30*time.Minute), inc)
+	clock.Advance(59 * time.Minute)
+	if runs != 1 {
+		t.Errorf("Got %d runs, want 1", runs)
+	}
+	if timers, _ := s.pending(); len(timers) != 0 {
+		t.Errorf("Got pending timers %v, want none", timers)
+	}
+}
+
+func TestSchedulerCancelBeforeKeepsLaterTimers(t *testing.T) {
+	start := time.Date(2026, 3, 7, 0, 0, 0, 0, time.UTC)
+	clock := util.NewFakeClock(start)
+	s := newTestScheduler(clock)
+	defer s.stop()
+
+	s.schedule("old", start.Add(time.Minute), func() {})
+	_, mark := s.extend(start)
+	s.schedule("new", start.Add(time.Minute), func() {})
+	s.cancelBefore(mark)
+
+	timers, _ := s.pending()
+	if len(timers) != 1 || timers[0].Key != "new" {
+		t.Errorf("Got pending timers %v, want only new", timers)
+	}
+}
+
+// raceTimers schedules testTimers timers due in a second, and then advances
+// clock past them while op runs on every key. It returns how many timers
+// ran.
+func raceTimers(clock *util.FakeClock, s *scheduler, op func(key string)) int32 {
+	var runs int32
+	for i := 0; i < testTimers; i++ {
+		s.schedule(fmt.Sprintf("timer%d", i), clock.Now().Add(time.Second), func() {
+			atomic.AddInt32(&runs, 1)
+		})
+	}
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		clock.Advance(time.Second)
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < testTimers; i++ {
+			op(fmt.Sprintf("timer%d", i))
+		}
+	}()
+	wg.Wait()
+	return atomic.LoadInt32(&runs)
+}
+
+func TestSchedulerCancelRacingTimers(t *testing.T) {
+	clock := util.NewFakeClock(time.Date(2026, 3, 7, 0, 0, 0, 0, time.UTC))
+	s := newTestScheduler(clock)
+	defer s.stop()
+
+	runs := raceTimers(clock, s, s.cancel)
+	if runs > testTimers {
+		t.Errorf("Got %d runs of %d timers", runs, testTimers)
+	}
+	if timers, _ := s.pending(); len(timers) != 0 {
+		t.Errorf("Got pending timers %v, want none", timers)
+	}
+}
+
+func TestSchedulerScheduleRacingTimers(t *testing.T) {
+	clock := util.NewFakeClock(time.Date(2026, 3, 7, 0, 0, 0, 0, time.UTC))
+	s := newTestScheduler(clock)
+	defer s.stop()
+
+	var rescheduled int32
+	runs := raceTimers(clock, s, func(key string) {
+		s.schedule(key, clock.Now().Add(time.Minute), func() {
+			atomic.AddInt32(&rescheduled, 1)
+		})
+	})
+	clock.Advance(2 * time.Minute)
+	// Every timer is replaced, whether or not it ran first
+	if runs > testTimers || rescheduled != testTimers {
+		t.Errorf("Got %d runs and %d runs of replacements, want %d replacements", runs, rescheduled, testTimers)
+	}
+	if timers, _ := s.pending(); len(timers) != 0 {
+		t.Errorf("Got pending timers %v, want none", timers)
+	}
+}
+
+func TestSchedulerCancelBeforeRacingTimers(t *testing.T) {
+	clock := util.NewFakeClock(time.Date(2026, 3, 7, 0, 0, 0, 0, time.UTC))
+	s := newTestScheduler(clock)
+	defer s.stop()
+
+	runs := raceTimers(clock, s, func(key string) {
+		_, mark := s.extend(clock.Now())
+		s.cancelBefore(mark)
+	})
+	if runs > testTimers {
+		t.Errorf("Got %d runs of %d timers", runs, testTimers)
+	}
+	if timers, _ := s.pending(); len(timers) != 0 {
+		t.Errorf("Got pending timers %v, want none", timers)
+	}
+}
+
+func TestSchedulerStopRacingTimers(t *testing.T) {
+	clock := util.NewFakeClock(time.Date(2026, 3, 7, 0, 0, 0, 0, time.UTC))
+	s := newTestScheduler(clock)
+
+	runs := raceTimers(clock, s, func(key string) {
+		s.stop()
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := s.wait(ctx); err != nil {
+		t.Fatalf("Timers did not return: %v", err)
+	}
+	if runs > testTimers {
+		t.Errorf("Got %d runs of %d timers", runs, testTimers)
+	}
+	if s.schedule("late", clock.Now().Add(time.Second), func() {}) {
+		t.Error("Timer was scheduled after stop")
+	}
+	if timers, _ := s.pending(); len(timers) != 0 {
+		t.Errorf("Got pending timers %v, want none", timers)
+	}
+}
@@ -0,0 +1,357 @@
+package bot
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/azaky/cpbot/clist"
+	"github.com/azaky/cpbot/config"
+	"github.com/azaky/cpbot/repository"
+	"github.com/azaky/cpbot/util"
+	"github.com/line/line-bot-sdk-go/linebot"
+)
+
+var errNotSet = errors.New("not set")
+
+// memoryStore is an in-memory repository.DailyStore, repository.QuotaStore,
+// repository.UserStore and repository.RateStore. LineBots of a test share one, as instances of cpbot
+// share Redis.
+type memoryStore struct {
+	clock     util.Clock
+	mu        sync.Mutex
+	daily     map[string]time.Time
+	delivered map[string]time.Time
+	claims    map[string]time.Time
+	timezones map[string]*time.Location
+	quiet     map[string]string
+	deferred  map[string][]string
+	dues      map[string]time.Time
+	quota     map[string]int
+	users     []string
+	rates     map[string]memoryRate
+	// err, if not nil, is returned when getting what to schedule
+	err error
+}
+
+var (
+	_ repository.DailyStore = (*memoryStore)(nil)
+	_ repository.QuotaStore = (*memoryStore)(nil)
+	_ repository.UserStore  = (*memoryStore)(nil)
+	_ repository.RateStore  = (*memoryStore)(nil)
+)
+
+// memoryRate is a window of hits that ends at end.
+type memoryRate struct {
+	end  time.Time
+	hits int
+}
+
+func newMemoryStore(clock util.Clock) *memoryStore {
+	return &memoryStore{
+		clock:     clock,
+		daily:     make(map[string]time.Time),
+		delivered: make(map[string]time.Time),
+		claims:    make(map[string]time.Time),
+		timezones: make(map[string]*time.Location),
+		quiet:     make(map[string]string),
+		deferred:  make(map[string][]string),
+		dues:      make(map[string]time.Time),
+		quota:     make(map[string]int),
+		rates:     make(map[string]memoryRate),
+	}
+}
+
+func memoryDailyMember(user string, t int) string {
+	return fmt.Sprintf("%s@%d", user, t)
+}
+
+func memoryClaimKey(user string, t int, occurrence time.Time) string {
+	return fmt.Sprintf("%s:%d", memoryDailyMember(user, t), occurrence.Unix())
+}
+
+// addDaily adds a reminder of user at t in tz, as of now.
+func (s *memoryStore) addDaily(user string, t int, tz *time.Location) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.clock.Now()
+	s.timezones[user] = tz
+	s.daily[memoryDailyMember(user, t)] = util.NextTime(now, t, tz)
+	s.delivered[memoryDailyMember(user, t)] = now
+}
+
+func (s *memoryStore) GetDailyUntil(to time.Time) ([]repository.UserTime, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return nil, s.err
+	}
+	var res []repository.UserTime
+	for member, next := range s.daily {
+		if next.After(to) {
+			continue
+		}
+		i := strings.LastIndex(member, "@")
+		var t int
+		fmt.Sscan(member[i+1:], &t)
+		res = append(res, repository.UserTime{User: member[:i], Time: t, Next: next})
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Next.Equal(res[j].Next) {
+			return memoryDailyMember(res[i].User, res[i].Time) < memoryDailyMember(res[j].User, res[j].Time)
+		}
+		return res[i].Next.Before(res[j].Next)
+	})
+	return res, nil
+}
+
+func (s *memoryStore) SetDailyNext(user string, t int, next time.Time) (interface{}, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.daily[memoryDailyMember(user, t)]; ok {
+		s.daily[memoryDailyMember(user, t)] = next
+	}
+	return nil, nil
+}
+
+func (s *memoryStore) ClaimDaily(user string, t int, occurrence time.Time, ttl time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !occurrence.After(s.delivered[memoryDailyMember(user, t)]) {
+		return false, nil
+	}
+	key := memoryClaimKey(user, t, occurrence)
+	if expiry, ok := s.claims[key]; ok && s.clock.Now().Before(expiry) {
+		return false, nil
+	}
+	s.claims[key] = s.clock.Now().Add(ttl)
+	return true, nil
+}
+
+func (s *memoryStore) CompleteDaily(user string, t int, occurrence, next time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.claims, memoryClaimKey(user, t, occurrence))
+	member := memoryDailyMember(user, t)
+	current, ok := s.daily[member]
+	if !ok {
+		return nil
+	}
+	s.delivered[member] = occurrence
+	if !current.After(occurrence) {
+		s.daily[member] = next
+	}
+	return nil
+}
+
+func (s *memoryStore) ReleaseDaily(user string, t int, occurrence time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.claims, memoryClaimKey(user, t, occurrence))
+	return nil
+}
+
+func (s *memoryStore) DeferMessages(user string, due time.Time, messages ...string) (interface{}, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.deferred[user] = append(s.deferred[user], messages...)
+	s.dues[user] = due
+	return nil, nil
+}
+
+func (s *memoryStore) PopDeferred(user string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	messages := s.deferred[user]
+	delete(s.deferred, user)
+	delete(s.dues, user)
+	return messages, nil
+}
+
+func (s *memoryStore) GetDeferredUntil(to time.Time) ([]repository.UserDue, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return nil, s.err
+	}
+	var res []repository.UserDue
+	for user, due := range s.dues {
+		if !due.After(to) {
+			res = append(res, repository.UserDue{User: user, Due: due.Unix()})
+		}
+	}
+	return res, nil
+}
+
+func (s *memoryStore) GetTimezone(user string) (*time.Location, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if tz, ok := s.timezones[user]; ok {
+		return tz, nil
+	}
+	return time.UTC, errNotSet
+}
+
+func (s *memoryStore) GetDailyWindow(user string) (string, error) {
+	return "", errNotSet
+}
+
+func (s *memoryStore) GetDailySkipEmpty(user string) (bool, error) {
+	return false, errNotSet
+}
+
+func (s *memoryStore) setError(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.err = err
+}
+
+func (s *memoryStore) setQuiet(user, quiet string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.quiet[user] = quiet
+}
+
+func (s *memoryStore) GetQuiet(user string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if quiet, ok := s.quiet[user]; ok {
+		return quiet, nil
+	}
+	return "", errNotSet
+}
+
+func (s *memoryStore) ReserveQuota(period string, n, limit int) (int, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	used := s.quota[period]
+	if limit > 0 && used+n > limit {
+		return used, false, nil
+	}
+	s.quota[period] = used + n
+	return used + n, true, nil
+}
+
+func (s *memoryStore) ReleaseQuota(period string, n int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.quota[period] -= n
+	return nil
+}
+
+func (s *memoryStore) GetQuotaUsed(period string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.quota[period], nil
+}
+
+func (s *memoryStore) GetUsers() ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.users...), nil
+}
+
+func (s *memoryStore) CountRate(key string, window time.Duration) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.clock.Now()
+	rate := s.rates[key]
+	if !now.Before(rate.end) {
+		rate = memoryRate{end: now.Add(window)}
+	}
+	rate.hits++
+	s.rates[key] = rate
+	return rate.hits, nil
+}
+
+// recordedPush is a push request to Line.
+type recordedPush struct {
+	To       string
+	At       time.Time
+	Messages int
+	Text     string
+}
+
+// pushRecorder stands in for Line, recording every push that succeeds.
+type pushRecorder struct {
+	clock util.Clock
+	mu    sync.Mutex
+	// fail, if not nil, decides whether a push fails
+	fail   func(to string) error
+	pushes []recordedPush
+	failed int
+}
+
+func (p *pushRecorder) send(to string, messages ...linebot.Message) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.fail != nil {
+		if err := p.fail(to); err != nil {
+			p.failed++
+			return err
+		}
+	}
+	var texts []string
+	for _, message := range messages {
+		if text, ok := message.(*linebot.TextMessage); ok {
+			texts = append(texts, text.Text)
+		}
+	}
+	p.pushes = append(p.pushes, recordedPush{To: to, At: p.clock.Now(), Messages: len(messages), Text: strings.Join(texts, "\n")})
+	return nil
+}
+
+func (p *pushRecorder) recorded() []recordedPush {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]recordedPush(nil), p.pushes...)
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// newTestClist returns a clist.Service that finds a single contest whatever
+// it is asked for, named after the start of the range asked for.
+func newTestClist() *clist.Service {
+	return clist.NewService("test:test", &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		name := "Test Round from " + req.URL.Query().Get("start__gte")
+		body := `{"objects":[{"start":"2026-03-10T12:00:00","end":"2026-03-10T14:00:00","duration":7200,"event":"` + name + `","href":"https://example.com/","id":1}]}`
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     make(http.Header),
+			Body:       ioutil.NopCloser(strings.NewReader(body)),
+			Request:    req,
+		}, nil
+	})})
+}
+
+// newTestLineBot returns a LineBot that keeps its state in store, and pushes
+// to pushes.
+func newTestLineBot(clock util.Clock, store *memoryStore, pushes *pushRecorder) *LineBot {
+	b := &LineBot{
+		clistService: newTestClist(),
+		clock:        clock,
+		daily:        store,
+		users:        store,
+		rates:        store,
+		scheduler:    newScheduler(clock),
+		config:       config.Line{MaxMessageLength: 1000, MaxInDuration: 30 * 24 * 3600},
+	}
+	b.dispatcher = &dispatcher{
+		send:    pushes.send,
+		repo:    store,
+		clock:   clock,
+		limiter: util.NewTokenBucket(clock, 1000, 1000),
+	}
+	// Replies are discarded, unless a test records them
+	b.sendReply = func(string, ...linebot.Message) error { return nil }
+	return b
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/azaky/cpbot/bot"
+	"github.com/azaky/cpbot/clist"
+	"github.com/azaky/cpbot/config"
+	"github.com/azaky/cpbot/logging"
+	"github.com/azaky/cpbot/repository"
+	"github.com/sirupsen/logrus"
+)
+
+const cliUsage = `Usage: cpbot [command]
+
+Without a command, cpbot runs the bot. Commands:
+
+  config           Validate the config and show the effective settings
+
+  dump             Write the whole repository as JSON to stdout
+  restore [FILE]   Restore the repository from a JSON dump in FILE, or stdin
+  broadcast [-types user,group,room] [-interval 100ms] [-dry-run] MESSAGE
+                   Push MESSAGE to every chat, or only to chats of the given types`
+
+var cliLog = logrus.WithField(logging.FieldComponent, "cli")
+
+// runCommand runs the operator command in args.
+func runCommand(cfg *config.Config, args []string) {
+	repo := repository.NewRedis("line", cfg.RedisEndpoint)
+	switch args[0] {
+	case "config":
+		fmt.Println(cfg.Report())
+		if err := cfg.Validate(); err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(1)
+		}
+
+	case "dump":
+		dump, err := repo.Dump()
+		if err != nil {
+			cliLog.WithError(err).Fatal("Error dumping repository")
+		}
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err = encoder.Encode(dump); err != nil {
+			cliLog.WithError(err).Fatal("Error writing dump")
+		}
+		cliLog.Infof("Dumped %d keys", len(dump.Keys))
+
+	case "restore":
+		in := os.Stdin
+		if len(args) > 1 {
+			f, err := os.Open(args[1])
+			if err != nil {
+				cliLog.WithError(err).Fatal("Error opening dump")
+			}
+			defer f.Close()
+			in = f
+		}
+		var dump repository.Dump
+		if err := json.NewDecoder(in).Decode(&dump); err != nil {
+			cliLog.WithError(err).Fatal("Error reading dump")
+		}
+		restored, err := repo.Restore(&dump)
+		if err != nil {
+			cliLog.WithError(err).Fatalf("Error restoring repository after %d keys", restored)
+		}
+		cliLog.Infof("Restored %d keys", restored)
+
+	case "broadcast":
+		flags := flag.NewFlagSet("broadcast", flag.ExitOnError)
+		typesFlag := flags.String("types", "", "comma separated types of chats to broadcast to, all if empty")
+		interval := flags.Duration("interval", 0, "interval between pushes")
+		dryRun := flags.Bool("dry-run", false, "only list the chats to broadcast to")
+		flags.Parse(args[1:])
+		message := strings.Join(flags.Args(), " ")
+		if message == "" {
+			fmt.Fprintln(os.Stderr, cliUsage)
+			os.Exit(2)
+		}
+		types, err := bot.ParseBroadcastTypes(*typesFlag)
+		if err != nil {
+			cliLog.Fatal(err.Error())
+		}
+
+		if err = cfg.Validate(); err != nil {
+			cliLog.Fatal(err.Error())
+		}
+		clistService := clist.NewService(cfg.ClistAPIKey, &http.Client{Timeout: 5 * time.Second})
+		lineBot := bot.NewLineBot(cfg.Line, clistService, cfg.RedisEndpoint)
+		opts := bot.BroadcastOptions{Types: types, Interval: *interval, DryRun: *dryRun}
+		result, err := lineBot.Broadcast([]string{message}, opts, func(p bot.BroadcastProgress) {
+			log := logging.WithChat(cliLog, p.Chat)
+			if p.Error != "" {
+				log.WithField(logrus.ErrorKey, p.Error).Warnf("[%d/%d] Failed", p.Done, p.Total)
+			} else if *dryRun {
+				log.Infof("[%d/%d] Would push", p.Done, p.Total)
+			} else {
+				log.Infof("[%d/%d] Pushed", p.Done, p.Total)
+			}
+		})
+		if err != nil {
+			cliLog.WithError(err).Fatal("Error broadcasting")
+		}
+		if result.DryRun {
+			cliLog.Infof("Would broadcast to %d chats", result.Total)
+			return
+		}
+		cliLog.Infof("Broadcast to %d of %d chats, %d failed", result.Sent, result.Total, len(result.Failed))
+		if len(result.Failed) > 0 {
+			os.Exit(1)
+		}
+
+	default:
+		fmt.Fprintln(os.Stderr, cliUsage)
+		os.Exit(2)
+	}
+}
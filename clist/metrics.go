@@ -0,0 +1,19 @@
+package clist
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// Metrics of requests to clist.by. Their names are part of the interface of
+// cpbot, do not change them.
+var (
+	// cpbot_clist_request_duration_seconds{result="success|failure"}, whose
+	// count of failures is the number of failed requests
+	requestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "cpbot_clist_request_duration_seconds",
+		Help:    "Latency of requests to clist.by.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"result"})
+)
+
+func init() {
+	prometheus.MustRegister(requestDuration)
+}
@@ -6,6 +6,7 @@ import (
 	"io/ioutil"
 	"net/http"
 	"strconv"
+	"sync/atomic"
 	"time"
 )
 
@@ -58,8 +59,11 @@ type responseObject struct {
 }
 
 type Service struct {
-	ApiKey     string
-	httpClient *http.Client
+	// lastSuccess is the unix nano time of the last successful request. It
+	// comes first to be 64-bit aligned for atomic access.
+	lastSuccess int64
+	ApiKey      string
+	httpClient  *http.Client
 }
 
 func NewService(apiKey string, httpClient *http.Client) *Service {
@@ -74,6 +78,18 @@ func (s *Service) getAuthorizationHeader() string {
 }
 
 func (s *Service) getContests(params map[string]string) ([]Contest, error) {
+	start := time.Now()
+	contests, err := s.requestContests(params)
+	if err != nil {
+		requestDuration.WithLabelValues("failure").Observe(time.Since(start).Seconds())
+		return nil, err
+	}
+	requestDuration.WithLabelValues("success").Observe(time.Since(start).Seconds())
+	atomic.StoreInt64(&s.lastSuccess, time.Now().UnixNano())
+	return contests, nil
+}
+
+func (s *Service) requestContests(params map[string]string) ([]Contest, error) {
 	req, err := http.NewRequest(http.MethodGet, apiURL, nil)
 	if err != nil {
 		return nil, err
@@ -104,6 +120,16 @@ func (s *Service) getContests(params map[string]string) ([]Contest, error) {
 	return obj.Objects, nil
 }
 
+// LastSuccess returns when the last successful request to clist.by was
+// made, or the zero time if there has not been any.
+func (s *Service) LastSuccess() time.Time {
+	unix := atomic.LoadInt64(&s.lastSuccess)
+	if unix == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, unix)
+}
+
 func (s *Service) GetAllContests() ([]Contest, error) {
 	return s.getContests(nil)
 }
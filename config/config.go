@@ -0,0 +1,327 @@
+// Package config loads the configuration of cpbot from an optional YAML
+// file and the environment, which takes precedence, and validates it.
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/azaky/cpbot/util"
+	"gopkg.in/yaml.v2"
+)
+
+// Line limits the length of a text message to 2000 characters.
+const lineMaxMessageLengthLimit = 2000
+
+var dailyDefaultRegex = regexp.MustCompile(`^\d{1,2}:\d{2}$`)
+
+type Config struct {
+	// Port to listen to
+	Port string `yaml:"port"`
+	// ShutdownTimeout is how long to wait for requests, and then for queued
+	// events and deliveries in progress, on shutdown, in seconds each
+	ShutdownTimeout int    `yaml:"shutdown_timeout"`
+	RedisEndpoint   string `yaml:"redis_endpoint"`
+	// ClistAPIKey is in the format username:key, without "ApiKey"
+	ClistAPIKey string `yaml:"clist_apikey"`
+	// ClistMaxAge is how long since the last successful request to clist.by
+	// before /readyz makes one to check it, in seconds
+	ClistMaxAge int `yaml:"clist_max_age"`
+	// AdminToken is the bearer token of the admin API, which is disabled
+	// if it is empty
+	AdminToken string    `yaml:"admin_token"`
+	Line       Line      `yaml:"line"`
+	Log        LogConfig `yaml:"log"`
+}
+
+type Line struct {
+	ChannelSecret    string `yaml:"channel_secret"`
+	ChannelToken     string `yaml:"channel_token"`
+	MaxMessageLength int    `yaml:"max_message_length"`
+	// MaxInputLength is the length of the longest message that is matched
+	// against commands
+	MaxInputLength int `yaml:"max_input_length"`
+	// MaxInDuration is the longest duration of the "in" command, and of
+	// daily reminder windows, in seconds
+	MaxInDuration int `yaml:"max_in_duration"`
+	// CommandRateChat and CommandRateUser are how many commands a chat and
+	// a user can send per minute, unlimited if 0
+	CommandRateChat int `yaml:"command_rate_chat"`
+	CommandRateUser int `yaml:"command_rate_user"`
+	// DailyDefault is the time of the daily reminder of new chats, as
+	// HH:MM in UTC
+	DailyDefault   string `yaml:"daily_default"`
+	DailySkipEmpty bool   `yaml:"daily_skip_empty"`
+	// DailyPeriod is the period of the daily job, in seconds
+	DailyPeriod int `yaml:"daily_period"`
+	// DailyGracePeriod is how long after its time a missed reminder is
+	// still delivered, in seconds
+	DailyGracePeriod int `yaml:"daily_grace_period"`
+	// Workers is the number of webhook events processed concurrently
+	Workers int `yaml:"workers"`
+	// QueueSize is how many webhook events each worker can queue before
+	// webhooks are rejected
+	QueueSize int `yaml:"queue_size"`
+	// PushRate is how many pushes are sent per second on average, and
+	// PushBurst how many can be sent at once
+	PushRate  float64 `yaml:"push_rate"`
+	PushBurst int     `yaml:"push_burst"`
+	// PushQuota is the number of pushes per month, unlimited if it is 0
+	PushQuota int `yaml:"push_quota"`
+	// PushQuotaReserve is the remaining quota under which only pushes to
+	// users, and not empty reminders, are sent
+	PushQuotaReserve int `yaml:"push_quota_reserve"`
+}
+
+type LogConfig struct {
+	Level       string `yaml:"level"`
+	Format      string `yaml:"format"`
+	HashChatIDs bool   `yaml:"hash_chat_ids"`
+	Redact      bool   `yaml:"redact"`
+}
+
+// Default returns the configuration used for everything that is not set.
+func Default() *Config {
+	return &Config{
+		Port:            "8080",
+		ShutdownTimeout: 25,
+		ClistMaxAge:     3600,
+		Line: Line{
+			MaxMessageLength: 1000,
+			MaxInputLength:   2000,
+			MaxInDuration:    30 * 24 * 3600,
+			CommandRateChat:  20,
+			CommandRateUser:  10,
+			DailyDefault:     "00:00",
+			DailyPeriod:      1800,
+			DailyGracePeriod: 3600,
+			Workers:          8,
+			QueueSize:        100,
+			PushRate:         10,
+			PushBurst:        10,
+		},
+		Log: LogConfig{
+			Level:  "info",
+			Format: "text",
+		},
+	}
+}
+
+// Load loads the configuration from the YAML file at path, if it is not
+// empty, and then from the environment. It does not validate it.
+func Load(path string) (*Config, error) {
+	c := Default()
+	if path != "" {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if err = yaml.UnmarshalStrict(data, c); err != nil {
+			return nil, fmt.Errorf("Invalid config file %s: %s", path, err.Error())
+		}
+	}
+
+	e := &envLoader{}
+	e.string(&c.Port, "PORT")
+	e.int(&c.ShutdownTimeout, "SHUTDOWN_TIMEOUT")
+	e.string(&c.RedisEndpoint, "REDIS_ENDPOINT")
+	e.string(&c.ClistAPIKey, "CLIST_APIKEY")
+	e.int(&c.ClistMaxAge, "CLIST_MAX_AGE")
+	e.string(&c.AdminToken, "ADMIN_TOKEN")
+	e.string(&c.Line.ChannelSecret, "LINE_CHANNEL_SECRET")
+	e.string(&c.Line.ChannelToken, "LINE_CHANNEL_TOKEN")
+	e.int(&c.Line.MaxMessageLength, "LINE_MAX_MESSAGE_LENGTH")
+	e.int(&c.Line.MaxInputLength, "LINE_MAX_INPUT_LENGTH")
+	e.int(&c.Line.MaxInDuration, "LINE_MAX_IN_DURATION")
+	e.int(&c.Line.CommandRateChat, "LINE_COMMAND_RATE_CHAT")
+	e.int(&c.Line.CommandRateUser, "LINE_COMMAND_RATE_USER")
+	e.string(&c.Line.DailyDefault, "LINE_DAILY_DEFAULT")
+	e.bool(&c.Line.DailySkipEmpty, "LINE_DAILY_SKIP_EMPTY")
+	e.int(&c.Line.DailyPeriod, "LINE_DAILY_PERIOD")
+	e.int(&c.Line.DailyGracePeriod, "LINE_DAILY_GRACE_PERIOD")
+	e.int(&c.Line.Workers, "LINE_WORKERS")
+	e.int(&c.Line.QueueSize, "LINE_QUEUE_SIZE")
+	e.float(&c.Line.PushRate, "LINE_PUSH_RATE")
+	e.int(&c.Line.PushBurst, "LINE_PUSH_BURST")
+	e.int(&c.Line.PushQuota, "LINE_PUSH_QUOTA")
+	e.int(&c.Line.PushQuotaReserve, "LINE_PUSH_QUOTA_RESERVE")
+	e.string(&c.Log.Level, "LOG_LEVEL")
+	e.string(&c.Log.Format, "LOG_FORMAT")
+	e.bool(&c.Log.HashChatIDs, "LOG_HASH_CHAT_IDS")
+	e.bool(&c.Log.Redact, "LOG_REDACT")
+	if len(e.errs) > 0 {
+		return nil, fmt.Errorf("Invalid environment:\n- %s", strings.Join(e.errs, "\n- "))
+	}
+	return c, nil
+}
+
+// envLoader overrides values with the environment variables that are set,
+// collecting every value that cannot be parsed.
+type envLoader struct {
+	errs []string
+}
+
+func (e *envLoader) string(v *string, name string) {
+	if s, ok := os.LookupEnv(name); ok {
+		*v = s
+	}
+}
+
+func (e *envLoader) int(v *int, name string) {
+	if s, ok := os.LookupEnv(name); ok && s != "" {
+		i, err := strconv.Atoi(s)
+		if err != nil {
+			e.errs = append(e.errs, fmt.Sprintf("%s must be an integer, got %q", name, s))
+			return
+		}
+		*v = i
+	}
+}
+
+func (e *envLoader) float(v *float64, name string) {
+	if s, ok := os.LookupEnv(name); ok && s != "" {
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			e.errs = append(e.errs, fmt.Sprintf("%s must be a number, got %q", name, s))
+			return
+		}
+		*v = f
+	}
+}
+
+func (e *envLoader) bool(v *bool, name string) {
+	if s, ok := os.LookupEnv(name); ok && s != "" {
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			e.errs = append(e.errs, fmt.Sprintf("%s must be true or false, got %q", name, s))
+			return
+		}
+		*v = b
+	}
+}
+
+// Validate checks the configuration, reporting every problem at once.
+func (c *Config) Validate() error {
+	var errs []string
+	if c.Port == "" {
+		errs = append(errs, "port (PORT) is required")
+	}
+	if c.ShutdownTimeout < 0 {
+		errs = append(errs, "shutdown_timeout (SHUTDOWN_TIMEOUT) must not be negative")
+	}
+	if c.RedisEndpoint == "" {
+		errs = append(errs, "redis_endpoint (REDIS_ENDPOINT) is required")
+	}
+	if c.ClistAPIKey == "" {
+		errs = append(errs, "clist_apikey (CLIST_APIKEY) is required")
+	}
+	if c.ClistMaxAge <= 0 {
+		errs = append(errs, "clist_max_age (CLIST_MAX_AGE) must be positive")
+	}
+	if c.Line.ChannelSecret == "" {
+		errs = append(errs, "line.channel_secret (LINE_CHANNEL_SECRET) is required")
+	}
+	if c.Line.ChannelToken == "" {
+		errs = append(errs, "line.channel_token (LINE_CHANNEL_TOKEN) is required")
+	}
+	if c.Line.MaxMessageLength < 1 || c.Line.MaxMessageLength > lineMaxMessageLengthLimit {
+		errs = append(errs, fmt.Sprintf("line.max_message_length (LINE_MAX_MESSAGE_LENGTH) must be in range [1, %d]", lineMaxMessageLengthLimit))
+	}
+	if c.Line.MaxInputLength <= 0 {
+		errs = append(errs, "line.max_input_length (LINE_MAX_INPUT_LENGTH) must be positive")
+	}
+	if c.Line.MaxInDuration <= 0 {
+		errs = append(errs, "line.max_in_duration (LINE_MAX_IN_DURATION) must be positive")
+	}
+	if c.Line.CommandRateChat < 0 {
+		errs = append(errs, "line.command_rate_chat (LINE_COMMAND_RATE_CHAT) must not be negative")
+	}
+	if c.Line.CommandRateUser < 0 {
+		errs = append(errs, "line.command_rate_user (LINE_COMMAND_RATE_USER) must not be negative")
+	}
+	if _, err := util.ParseTime(c.Line.DailyDefault); err != nil || !dailyDefaultRegex.MatchString(c.Line.DailyDefault) {
+		errs = append(errs, "line.daily_default (LINE_DAILY_DEFAULT) must be a time as HH:MM")
+	}
+	if c.Line.DailyPeriod <= 0 {
+		errs = append(errs, "line.daily_period (LINE_DAILY_PERIOD) must be positive")
+	}
+	if c.Line.DailyGracePeriod < 0 {
+		errs = append(errs, "line.daily_grace_period (LINE_DAILY_GRACE_PERIOD) must not be negative")
+	}
+	if c.Line.Workers <= 0 {
+		errs = append(errs, "line.workers (LINE_WORKERS) must be positive")
+	}
+	if c.Line.QueueSize <= 0 {
+		errs = append(errs, "line.queue_size (LINE_QUEUE_SIZE) must be positive")
+	}
+	if c.Line.PushRate <= 0 {
+		errs = append(errs, "line.push_rate (LINE_PUSH_RATE) must be positive")
+	}
+	if c.Line.PushBurst <= 0 {
+		errs = append(errs, "line.push_burst (LINE_PUSH_BURST) must be positive")
+	}
+	if c.Line.PushQuota < 0 {
+		errs = append(errs, "line.push_quota (LINE_PUSH_QUOTA) must not be negative")
+	}
+	if c.Line.PushQuotaReserve < 0 || (c.Line.PushQuota > 0 && c.Line.PushQuotaReserve >= c.Line.PushQuota) {
+		errs = append(errs, "line.push_quota_reserve (LINE_PUSH_QUOTA_RESERVE) must not be negative, and must be less than line.push_quota")
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("Invalid config:\n- %s", strings.Join(errs, "\n- "))
+	}
+	return nil
+}
+
+// Report describes the effective configuration, with secrets masked.
+func (c *Config) Report() string {
+	var buffer bytes.Buffer
+	buffer.WriteString("Effective config:")
+	line := func(name string, value interface{}) {
+		buffer.WriteString(fmt.Sprintf("\n  %s: %v", name, value))
+	}
+	line("port", c.Port)
+	line("shutdown_timeout", c.ShutdownTimeout)
+	line("redis_endpoint", c.RedisEndpoint)
+	line("clist_apikey", maskSecret(c.ClistAPIKey))
+	line("clist_max_age", c.ClistMaxAge)
+	line("admin_token", maskSecret(c.AdminToken))
+	line("line.channel_secret", maskSecret(c.Line.ChannelSecret))
+	line("line.channel_token", maskSecret(c.Line.ChannelToken))
+	line("line.max_message_length", c.Line.MaxMessageLength)
+	line("line.max_input_length", c.Line.MaxInputLength)
+	line("line.max_in_duration", c.Line.MaxInDuration)
+	line("line.command_rate_chat", c.Line.CommandRateChat)
+	line("line.command_rate_user", c.Line.CommandRateUser)
+	line("line.daily_default", c.Line.DailyDefault)
+	line("line.daily_skip_empty", c.Line.DailySkipEmpty)
+	line("line.daily_period", c.Line.DailyPeriod)
+	line("line.daily_grace_period", c.Line.DailyGracePeriod)
+	line("line.workers", c.Line.Workers)
+	line("line.queue_size", c.Line.QueueSize)
+	line("line.push_rate", c.Line.PushRate)
+	line("line.push_burst", c.Line.PushBurst)
+	line("line.push_quota", c.Line.PushQuota)
+	line("line.push_quota_reserve", c.Line.PushQuotaReserve)
+	line("log.level", c.Log.Level)
+	line("log.format", c.Log.Format)
+	line("log.hash_chat_ids", c.Log.HashChatIDs)
+	line("log.redact", c.Log.Redact)
+	return buffer.String()
+}
+
+// maskSecret hides all but the last few characters of a secret, enough to
+// tell which one is in use.
+func maskSecret(s string) string {
+	if s == "" {
+		return "(not set)"
+	}
+	if len(s) < 12 {
+		return "****"
+	}
+	return "****" + s[len(s)-4:]
+}
@@ -0,0 +1,214 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+// envNames are every environment variable read by Load.
+var envNames = []string{
+	"PORT", "SHUTDOWN_TIMEOUT", "REDIS_ENDPOINT", "CLIST_APIKEY", "CLIST_MAX_AGE", "ADMIN_TOKEN",
+	"LINE_CHANNEL_SECRET", "LINE_CHANNEL_TOKEN", "LINE_MAX_MESSAGE_LENGTH", "LINE_MAX_INPUT_LENGTH",
+	"LINE_MAX_IN_DURATION", "LINE_COMMAND_RATE_CHAT", "LINE_COMMAND_RATE_USER", "LINE_DAILY_DEFAULT",
+	"LINE_DAILY_SKIP_EMPTY", "LINE_DAILY_PERIOD", "LINE_DAILY_GRACE_PERIOD", "LINE_WORKERS",
+	"LINE_QUEUE_SIZE", "LINE_PUSH_RATE", "LINE_PUSH_BURST", "LINE_PUSH_QUOTA", "LINE_PUSH_QUOTA_RESERVE",
+	"LOG_LEVEL", "LOG_FORMAT", "LOG_HASH_CHAT_IDS", "LOG_REDACT",
+}
+
+// setEnv replaces the environment read by Load with env, and returns a
+// function that restores it.
+func setEnv(env map[string]string) func() {
+	saved := make(map[string]string)
+	for _, name := range envNames {
+		if value, ok := os.LookupEnv(name); ok {
+			saved[name] = value
+		}
+		os.Unsetenv(name)
+	}
+	for name, value := range env {
+		os.Setenv(name, value)
+	}
+	return func() {
+		for _, name := range envNames {
+			os.Unsetenv(name)
+		}
+		for name, value := range saved {
+			os.Setenv(name, value)
+		}
+	}
+}
+
+func writeConfigFile(t *testing.T, content string) string {
+	f, err := ioutil.TempFile("", "cpbot-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err = f.WriteString(content); err != nil {
+		t.Fatal(err)
+	}
+	return f.Name()
+}
+
+func validConfig() *Config {
+	c := Default()
+	c.RedisEndpoint = "localhost:6379"
+	c.ClistAPIKey = "user:key"
+	c.Line.ChannelSecret = "secret"
+	c.Line.ChannelToken = "token"
+	return c
+}
+
+func TestValidate(t *testing.T) {
+	if err := validConfig().Validate(); err != nil {
+		t.Fatalf("Valid config is invalid: %s", err)
+	}
+
+	tests := []struct {
+		name   string
+		modify func(c *Config)
+		want   string
+	}{
+		{"no port", func(c *Config) { c.Port = "" }, "port (PORT)"},
+		{"negative shutdown timeout", func(c *Config) { c.ShutdownTimeout = -1 }, "shutdown_timeout"},
+		{"no redis", func(c *Config) { c.RedisEndpoint = "" }, "redis_endpoint"},
+		{"no clist api key", func(c *Config) { c.ClistAPIKey = "" }, "clist_apikey"},
+		{"zero clist max age", func(c *Config) { c.ClistMaxAge = 0 }, "clist_max_age"},
+		{"no channel secret", func(c *Config) { c.Line.ChannelSecret = "" }, "line.channel_secret"},
+		{"no channel token", func(c *Config) { c.Line.ChannelToken = "" }, "line.channel_token"},
+		{"zero message length", func(c *Config) { c.Line.MaxMessageLength = 0 }, "line.max_message_length"},
+		{"message length over limit", func(c *Config) { c.Line.MaxMessageLength = 2001 }, "line.max_message_length"},
+		{"zero input length", func(c *Config) { c.Line.MaxInputLength = 0 }, "line.max_input_length"},
+		{"zero in duration", func(c *Config) { c.Line.MaxInDuration = 0 }, "line.max_in_duration"},
+		{"negative chat rate", func(c *Config) { c.Line.CommandRateChat = -1 }, "line.command_rate_chat"},
+		{"negative user rate", func(c *Config) { c.Line.CommandRateUser = -1 }, "line.command_rate_user"},
+		{"daily default without minutes", func(c *Config) { c.Line.DailyDefault = "9" }, "line.daily_default"},
+		{"daily default out of range", func(c *Config) { c.Line.DailyDefault = "24:00" }, "line.daily_default"},
+		{"daily default with seconds", func(c *Config) { c.Line.DailyDefault = "09:00:00" }, "line.daily_default"},
+		{"zero daily period", func(c *Config) { c.Line.DailyPeriod = 0 }, "line.daily_period"},
+		{"negative grace period", func(c *Config) { c.Line.DailyGracePeriod = -1 }, "line.daily_grace_period"},
+		{"zero workers", func(c *Config) { c.Line.Workers = 0 }, "line.workers"},
+		{"zero queue size", func(c *Config) { c.Line.QueueSize = 0 }, "line.queue_size"},
+		{"zero push rate", func(c *Config) { c.Line.PushRate = 0 }, "line.push_rate"},
+		{"zero push burst", func(c *Config) { c.Line.PushBurst = 0 }, "line.push_burst"},
+		{"negative push quota", func(c *Config) { c.Line.PushQuota = -1 }, "line.push_quota"},
+		{"negative quota reserve", func(c *Config) { c.Line.PushQuotaReserve = -1 }, "line.push_quota_reserve"},
+		{"quota reserve over quota", func(c *Config) { c.Line.PushQuota, c.Line.PushQuotaReserve = 100, 100 }, "line.push_quota_reserve"},
+	}
+	for _, test := range tests {
+		c := validConfig()
+		test.modify(c)
+		err := c.Validate()
+		if err == nil {
+			t.Errorf("%s: config is valid", test.name)
+			continue
+		}
+		if !strings.Contains(err.Error(), test.want) {
+			t.Errorf("%s: got %q, want it to mention %s", test.name, err.Error(), test.want)
+		}
+	}
+
+	// Every problem is reported at once
+	c := validConfig()
+	c.Port = ""
+	c.Line.Workers = 0
+	err := c.Validate()
+	if err == nil || !strings.Contains(err.Error(), "port (PORT)") || !strings.Contains(err.Error(), "line.workers") {
+		t.Errorf("Got %v, want both problems reported", err)
+	}
+}
+
+func TestLoadEnvOverridesFile(t *testing.T) {
+	path := writeConfigFile(t, `
+port: "9000"
+clist_apikey: file:key
+line:
+  max_message_length: 500
+  push_rate: 5
+  daily_skip_empty: false
+log:
+  format: json
+`)
+	defer os.Remove(path)
+	defer setEnv(map[string]string{
+		"PORT":                  "7000",
+		"LINE_PUSH_RATE":        "2.5",
+		"LINE_DAILY_SKIP_EMPTY": "true",
+		"LOG_FORMAT":            "text",
+		// Empty numbers are ignored
+		"LINE_MAX_MESSAGE_LENGTH": "",
+	})()
+
+	c, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %s", err)
+	}
+	tests := []struct {
+		name      string
+		got, want interface{}
+	}{
+		{"port from env", c.Port, "7000"},
+		{"push rate from env", c.Line.PushRate, 2.5},
+		{"daily skip empty from env", c.Line.DailySkipEmpty, true},
+		{"log format from env", c.Log.Format, "text"},
+		{"clist api key from file", c.ClistAPIKey, "file:key"},
+		{"max message length from file", c.Line.MaxMessageLength, 500},
+		{"workers by default", c.Line.Workers, 8},
+	}
+	for _, test := range tests {
+		if test.got != test.want {
+			t.Errorf("%s: got %v, want %v", test.name, test.got, test.want)
+		}
+	}
+}
+
+func TestLoadInvalidEnv(t *testing.T) {
+	tests := []struct {
+		name, value string
+	}{
+		{"SHUTDOWN_TIMEOUT", "25s"},
+		{"LINE_MAX_MESSAGE_LENGTH", "1e3"},
+		{"LINE_PUSH_RATE", "fast"},
+		{"LINE_DAILY_SKIP_EMPTY", "yes"},
+		{"LOG_REDACT", "2"},
+	}
+	for _, test := range tests {
+		restore := setEnv(map[string]string{test.name: test.value})
+		_, err := Load("")
+		restore()
+		if err == nil || !strings.Contains(err.Error(), test.name) {
+			t.Errorf("%s=%s: got %v, want an error about %s", test.name, test.value, err, test.name)
+		}
+	}
+
+	// Every invalid value is reported at once
+	defer setEnv(map[string]string{"LINE_WORKERS": "many", "LINE_QUEUE_SIZE": "lots"})()
+	_, err := Load("")
+	if err == nil || !strings.Contains(err.Error(), "LINE_WORKERS") || !strings.Contains(err.Error(), "LINE_QUEUE_SIZE") {
+		t.Errorf("Got %v, want both invalid values reported", err)
+	}
+}
+
+func TestLoadInvalidFile(t *testing.T) {
+	defer setEnv(nil)()
+	tests := []struct {
+		name, content string
+	}{
+		{"unknown field", "line:\n  max_message_lenght: 500\n"},
+		{"wrong type", "line:\n  workers: many\n"},
+		{"not yaml", "port: [\n"},
+	}
+	for _, test := range tests {
+		path := writeConfigFile(t, test.content)
+		_, err := Load(path)
+		os.Remove(path)
+		if err == nil {
+			t.Errorf("%s: loaded an invalid config file", test.name)
+		}
+	}
+	if _, err := Load("/nonexistent/cpbot.yaml"); err == nil {
+		t.Errorf("Loaded a missing config file")
+	}
+}
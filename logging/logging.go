@@ -0,0 +1,92 @@
+// Package logging configures the structured logger of cpbot, and keeps
+// personal data out of logs when asked to.
+package logging
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Names of fields shared by every part of cpbot.
+const (
+	FieldPlatform  = "platform"
+	FieldComponent = "component"
+	FieldChat      = "chat"
+	FieldUser      = "user"
+	FieldCommand   = "command"
+	FieldRequestID = "request_id"
+	FieldEventID   = "event_id"
+	FieldText      = "text"
+)
+
+var (
+	hashChatIDs   bool
+	redactContent bool
+)
+
+// Options configures the logger.
+type Options struct {
+	// Level is one of debug, info, warn or error
+	Level string
+	// Format is either text or json
+	Format string
+	// HashChatIDs logs hashes of chat and user IDs instead of the IDs
+	HashChatIDs bool
+	// Redact leaves the content of messages out of logs
+	Redact bool
+}
+
+// Setup configures the standard logrus logger, which every part of cpbot
+// logs to.
+func Setup(opts Options) error {
+	level := logrus.InfoLevel
+	if opts.Level != "" {
+		var err error
+		level, err = logrus.ParseLevel(opts.Level)
+		if err != nil {
+			return err
+		}
+	}
+	logrus.SetLevel(level)
+
+	switch strings.ToLower(opts.Format) {
+	case "", "text":
+		logrus.SetFormatter(&logrus.TextFormatter{})
+	case "json":
+		logrus.SetFormatter(&logrus.JSONFormatter{})
+	default:
+		return fmt.Errorf("Invalid log format: %s", opts.Format)
+	}
+
+	hashChatIDs = opts.HashChatIDs
+	redactContent = opts.Redact
+	return nil
+}
+
+// ChatID returns id as it should be logged: hashed if HashChatIDs is set.
+// Hashes are stable, so that logs of the same chat can still be correlated.
+func ChatID(id string) string {
+	if !hashChatIDs || id == "" {
+		return id
+	}
+	sum := sha256.Sum256([]byte(id))
+	return hex.EncodeToString(sum[:6])
+}
+
+// Content returns the content of a message as it should be logged: only its
+// length if Redact is set.
+func Content(text string) string {
+	if !redactContent {
+		return text
+	}
+	return fmt.Sprintf("[redacted %d bytes]", len(text))
+}
+
+// WithChat adds the id of chat to e.
+func WithChat(e *logrus.Entry, chat string) *logrus.Entry {
+	return e.WithField(FieldChat, ChatID(chat))
+}
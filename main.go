@@ -1,33 +1,63 @@
 package main
 
 import (
-	"log"
+	"context"
 	"net/http"
 	"os"
-	"strconv"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/azaky/cpbot/bot"
 	"github.com/azaky/cpbot/clist"
+	"github.com/azaky/cpbot/config"
+	"github.com/azaky/cpbot/logging"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/sirupsen/logrus"
 )
 
 func main() {
+	cfg, err := config.Load(os.Getenv("CONFIG_FILE"))
+	if err != nil {
+		logrus.Fatal(err.Error())
+	}
+	err = logging.Setup(logging.Options{
+		Level:       cfg.Log.Level,
+		Format:      cfg.Log.Format,
+		HashChatIDs: cfg.Log.HashChatIDs,
+		Redact:      cfg.Log.Redact,
+	})
+	if err != nil {
+		logrus.Fatalf("Error setting up logging: %s", err.Error())
+	}
+
+	if len(os.Args) > 1 {
+		runCommand(cfg, os.Args[1:])
+		return
+	}
 
-	clistService := clist.NewService(os.Getenv("CLIST_APIKEY"), &http.Client{Timeout: 5 * time.Second})
+	if err = cfg.Validate(); err != nil {
+		logrus.Fatal(err.Error())
+	}
+	logrus.Info(cfg.Report())
+
+	clistService := clist.NewService(cfg.ClistAPIKey, &http.Client{Timeout: 5 * time.Second})
 
 	// Setup LineBot
-	lineBot := bot.NewLineBot(
-		os.Getenv("LINE_CHANNEL_SECRET"),
-		os.Getenv("LINE_CHANNEL_TOKEN"),
-		clistService,
-		os.Getenv("REDIS_ENDPOINT"),
-	)
+	lineBot := bot.NewLineBot(cfg.Line, clistService, cfg.RedisEndpoint)
 	http.HandleFunc("/line/callback", lineBot.EventHandler)
-	lineDailyDuration, err := strconv.ParseInt(os.Getenv("LINE_DAILY_PERIOD"), 10, 64)
-	if err != nil {
-		lineDailyDuration = 1800
+	lineBot.StartDailyJob(time.Duration(cfg.Line.DailyPeriod)*time.Second, time.Duration(cfg.Line.DailyGracePeriod)*time.Second)
+
+	// Setup admin API, only if it has a token
+	if cfg.AdminToken != "" {
+		http.Handle("/admin/", lineBot.AdminHandler(cfg.AdminToken))
 	}
-	lineBot.StartDailyJob(time.Duration(lineDailyDuration) * time.Second)
+
+	http.Handle("/metrics", promhttp.Handler())
+
+	// Setup health checks
+	http.Handle("/healthz", lineBot.HealthHandler())
+	http.Handle("/readyz", lineBot.ReadyHandler(time.Duration(cfg.ClistMaxAge)*time.Second))
 
 	// Setup root endpoint
 	http.HandleFunc("/", func(w http.ResponseWriter, req *http.Request) {
@@ -36,7 +66,33 @@ func main() {
 		w.Write([]byte(`{"message":"Hello from cpbot"}`))
 	})
 
-	if err := http.ListenAndServe(":"+os.Getenv("PORT"), nil); err != nil {
-		log.Fatal(err)
+	server := &http.Server{Addr: ":" + cfg.Port}
+	go func() {
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			logrus.Fatal(err)
+		}
+	}()
+
+	// Shutdown gracefully on SIGTERM, which is sent on every deploy
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, os.Interrupt)
+	sig := <-signals
+	logrus.Infof("Received %s, shutting down", sig)
+
+	timeout := time.Duration(cfg.ShutdownTimeout) * time.Second
+	// Stop scheduling first, so that no delivery starts while waiting for
+	// webhooks in progress
+	lineBot.StopDailyJob()
+	// Requests and the work they queue wait for each other in turn, each
+	// with a timeout of their own, so that slow requests do not cut short
+	// the events they queued
+	serverCtx, cancelServer := context.WithTimeout(context.Background(), timeout)
+	defer cancelServer()
+	if err := server.Shutdown(serverCtx); err != nil {
+		logrus.WithError(err).Warn("Requests in progress did not finish in time")
 	}
+	botCtx, cancelBot := context.WithTimeout(context.Background(), timeout)
+	defer cancelBot()
+	lineBot.Shutdown(botCtx)
+	logrus.Info("Shut down")
 }
@@ -0,0 +1,163 @@
+package repository
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+// Dump is a snapshot of every key of the repository, for backing it up or
+// moving it to another deployment. Keys are relative to the prefix of the
+// repository, so a dump can be restored under another prefix.
+type Dump struct {
+	Keys []DumpKey `json:"keys"`
+}
+
+// DumpKey is a single key of a Dump. Only the field matching Type is set.
+// TTL is the remaining time to live of the key in milliseconds, or zero if
+// the key does not expire.
+type DumpKey struct {
+	Key    string            `json:"key"`
+	Type   string            `json:"type"`
+	TTL    int64             `json:"ttl,omitempty"`
+	String string            `json:"string,omitempty"`
+	Hash   map[string]string `json:"hash,omitempty"`
+	Set    []string          `json:"set,omitempty"`
+	ZSet   []ZSetMember      `json:"zset,omitempty"`
+	List   []string          `json:"list,omitempty"`
+}
+
+type ZSetMember struct {
+	Member string  `json:"member"`
+	Score  float64 `json:"score"`
+}
+
+// Dump returns all keys of the repository, except locks, which are only
+// meaningful to the instances that hold them, and short-lived rate limits.
+func (r *Redis) Dump() (*Dump, error) {
+	conn := r.pool.Get()
+	defer conn.Close()
+
+	prefix := r.prefix + ":"
+	dump := &Dump{}
+	cursor := 0
+	for {
+		reply, err := redis.Values(conn.Do("SCAN", cursor, "MATCH", prefix+"*", "COUNT", 100))
+		if err != nil {
+			return nil, err
+		}
+		cursor, _ = redis.Int(reply[0], nil)
+		keys, _ := redis.Strings(reply[1], nil)
+		for _, key := range keys {
+			if strings.HasPrefix(key, r.getLockKey("")) || strings.HasPrefix(key, r.getRateKey("")) {
+				continue
+			}
+			k, err := dumpKey(conn, key)
+			if err == redis.ErrNil {
+				// Expired or removed while dumping
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			k.Key = strings.TrimPrefix(key, prefix)
+			dump.Keys = append(dump.Keys, k)
+		}
+		if cursor == 0 {
+			break
+		}
+	}
+	return dump, nil
+}
+
+func dumpKey(conn redis.Conn, key string) (DumpKey, error) {
+	var k DumpKey
+	t, err := redis.String(conn.Do("TYPE", key))
+	if err != nil {
+		return k, err
+	}
+	k.Type = t
+	switch t {
+	case "none":
+		return k, redis.ErrNil
+	case "string":
+		k.String, err = redis.String(conn.Do("GET", key))
+	case "hash":
+		k.Hash, err = redis.StringMap(conn.Do("HGETALL", key))
+	case "set":
+		k.Set, err = redis.Strings(conn.Do("SMEMBERS", key))
+	case "list":
+		k.List, err = redis.Strings(conn.Do("LRANGE", key, 0, -1))
+	case "zset":
+		var reply []interface{}
+		reply, err = redis.Values(conn.Do("ZRANGE", key, 0, -1, "WITHSCORES"))
+		if err == nil {
+			err = redis.ScanSlice(reply, &k.ZSet)
+		}
+	default:
+		err = fmt.Errorf("Unsupported type of %s: %s", key, t)
+	}
+	if err != nil {
+		return k, err
+	}
+	ttl, err := redis.Int64(conn.Do("PTTL", key))
+	if err != nil {
+		return k, err
+	}
+	if ttl == -2 {
+		// Expired while dumping
+		return k, redis.ErrNil
+	}
+	if ttl > 0 {
+		k.TTL = ttl
+	}
+	return k, nil
+}
+
+// Restore writes every key of dump into the repository, replacing existing
+// keys with the same name, and expires them after their remaining time to
+// live as of the dump. Keys that are not in dump are left untouched.
+// It returns the number of keys restored.
+func (r *Redis) Restore(dump *Dump) (int, error) {
+	conn := r.pool.Get()
+	defer conn.Close()
+
+	restored := 0
+	for _, k := range dump.Keys {
+		key := fmt.Sprintf("%s:%s", r.prefix, k.Key)
+		conn.Send("MULTI")
+		conn.Send("DEL", key)
+		switch k.Type {
+		case "string":
+			conn.Send("SET", key, k.String)
+		case "hash":
+			for field, value := range k.Hash {
+				conn.Send("HSET", key, field, value)
+			}
+		case "set":
+			for _, member := range k.Set {
+				conn.Send("SADD", key, member)
+			}
+		case "list":
+			for _, value := range k.List {
+				conn.Send("RPUSH", key, value)
+			}
+		case "zset":
+			for _, m := range k.ZSet {
+				conn.Send("ZADD", key, m.Score, m.Member)
+			}
+		default:
+			conn.Do("DISCARD")
+			return restored, fmt.Errorf("Unsupported type of %s: %s", k.Key, k.Type)
+		}
+		if k.TTL > 0 {
+			conn.Send("PEXPIRE", key, k.TTL)
+		}
+		if _, err := conn.Do("EXEC"); err != nil {
+			return restored, err
+		}
+		restored++
+	}
+	return restored, nil
+}
@@ -0,0 +1,92 @@
+package repository
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestDumpRestore(t *testing.T) {
+	r, db := newFakeRedis("line")
+	db.data["line:users"] = map[string]bool{"user:U1": true, "group:C1": true}
+	db.data["line:settings:user:U1"] = map[string]string{"timezone": "Asia/Jakarta", "quiet": "22:00-06:00"}
+	db.data["line:daily"] = fakeZSet{"user:U1@32400": 1782957600}
+	db.data["line:deferred:user:U1"] = []string{"first", "second"}
+	db.data["line:quota:2026-07"] = "42"
+	db.ttl["line:quota:2026-07"] = 86400000
+	// Locks and rate limits are left out
+	db.data["line:lock:event:E1"] = "1"
+	db.ttl["line:lock:event:E1"] = 60000
+	db.data["line:rate:user:U1"] = fakeZSet{"1": 1}
+	// So are keys of other prefixes
+	db.data["other:users"] = map[string]bool{"user:U2": true}
+
+	dump, err := r.Dump()
+	if err != nil {
+		t.Fatalf("Dump: %s", err)
+	}
+	if len(dump.Keys) != 5 {
+		t.Errorf("Dump has %d keys, want 5: %+v", len(dump.Keys), dump.Keys)
+	}
+	for _, k := range dump.Keys {
+		if k.Key == "quota:2026-07" && k.TTL != 86400000 {
+			t.Errorf("TTL of %s = %d, want 86400000", k.Key, k.TTL)
+		} else if k.Key != "quota:2026-07" && k.TTL != 0 {
+			t.Errorf("TTL of %s = %d, want 0", k.Key, k.TTL)
+		}
+	}
+
+	// Dumps go through JSON on their way to the other deployment
+	blob, err := json.Marshal(dump)
+	if err != nil {
+		t.Fatalf("Marshal: %s", err)
+	}
+	var restoredDump Dump
+	if err := json.Unmarshal(blob, &restoredDump); err != nil {
+		t.Fatalf("Unmarshal: %s", err)
+	}
+
+	other, otherDB := newFakeRedis("copy")
+	otherDB.data["copy:settings:user:U1"] = map[string]string{"dailywindow": "48h"}
+	otherDB.data["copy:kept"] = "kept"
+	restored, err := other.Restore(&restoredDump)
+	if err != nil {
+		t.Fatalf("Restore: %s", err)
+	}
+	if restored != 5 {
+		t.Errorf("Restore = %d, want 5", restored)
+	}
+	want := map[string]interface{}{
+		"copy:users":            map[string]bool{"user:U1": true, "group:C1": true},
+		"copy:settings:user:U1": map[string]string{"timezone": "Asia/Jakarta", "quiet": "22:00-06:00"},
+		"copy:daily":            fakeZSet{"user:U1@32400": 1782957600},
+		"copy:deferred:user:U1": []string{"first", "second"},
+		"copy:quota:2026-07":    "42",
+		"copy:kept":             "kept",
+	}
+	if !reflect.DeepEqual(otherDB.data, want) {
+		t.Errorf("Restored keys = %v, want %v", otherDB.data, want)
+	}
+	wantTTL := map[string]int64{"copy:quota:2026-07": 86400000}
+	if !reflect.DeepEqual(otherDB.ttl, wantTTL) {
+		t.Errorf("Restored TTLs = %v, want %v", otherDB.ttl, wantTTL)
+	}
+}
+
+func TestRestoreUnsupportedType(t *testing.T) {
+	r, db := newFakeRedis("line")
+	dump := &Dump{Keys: []DumpKey{
+		{Key: "users", Type: "set", Set: []string{"user:U1"}},
+		{Key: "stream", Type: "stream"},
+	}}
+	restored, err := r.Restore(dump)
+	if err == nil {
+		t.Errorf("Restore of an unsupported type succeeded")
+	}
+	if restored != 1 {
+		t.Errorf("Restore = %d, want 1", restored)
+	}
+	if _, ok := db.data["line:stream"]; ok {
+		t.Errorf("Key of an unsupported type was restored")
+	}
+}
@@ -0,0 +1,406 @@
+package repository
+
+import (
+	"fmt"
+	"math"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+// fakeRedis is an in-memory stand-in for the subset of Redis used by the
+// repository, so that it can be tested without a server.
+// Times to live do not pass; keys only keep the time they were given.
+type fakeRedis struct {
+	data map[string]interface{}
+	ttl  map[string]int64
+}
+
+func newFakeRedis(prefix string) (*Redis, *fakeRedis) {
+	db := &fakeRedis{data: map[string]interface{}{}, ttl: map[string]int64{}}
+	r := &Redis{
+		pool: &redis.Pool{
+			Dial: func() (redis.Conn, error) {
+				return &fakeConn{db: db}, nil
+			},
+		},
+		prefix: prefix,
+	}
+	return r, db
+}
+
+type fakeZSet map[string]float64
+
+// sorted returns the members of z ordered by score, then by member.
+func (z fakeZSet) sorted() []string {
+	members := make([]string, 0, len(z))
+	for m := range z {
+		members = append(members, m)
+	}
+	sort.Slice(members, func(i, j int) bool {
+		if z[members[i]] != z[members[j]] {
+			return z[members[i]] < z[members[j]]
+		}
+		return members[i] < members[j]
+	})
+	return members
+}
+
+func (db *fakeRedis) hash(key string) map[string]string {
+	h, ok := db.data[key].(map[string]string)
+	if !ok {
+		h = map[string]string{}
+		db.data[key] = h
+	}
+	return h
+}
+
+func (db *fakeRedis) zset(key string) fakeZSet {
+	z, ok := db.data[key].(fakeZSet)
+	if !ok {
+		z = fakeZSet{}
+		db.data[key] = z
+	}
+	return z
+}
+
+// clean removes key if it holds an empty collection, as Redis does.
+func (db *fakeRedis) clean(key string) {
+	empty := false
+	switch v := db.data[key].(type) {
+	case map[string]string:
+		empty = len(v) == 0
+	case fakeZSet:
+		empty = len(v) == 0
+	}
+	if empty {
+		delete(db.data, key)
+		delete(db.ttl, key)
+	}
+}
+
+func parseScore(s string) (float64, bool, error) {
+	exclusive := strings.HasPrefix(s, "(")
+	s = strings.TrimPrefix(s, "(")
+	switch s {
+	case "-inf":
+		return math.Inf(-1), exclusive, nil
+	case "+inf", "inf":
+		return math.Inf(1), exclusive, nil
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	return f, exclusive, err
+}
+
+func formatScore(f float64) []byte {
+	return []byte(strconv.FormatFloat(f, 'f', -1, 64))
+}
+
+func (db *fakeRedis) do(cmd string, args []string) (interface{}, error) {
+	switch cmd {
+	case "PING":
+		return "PONG", nil
+	case "GET":
+		s, ok := db.data[args[0]].(string)
+		if !ok {
+			return nil, nil
+		}
+		return []byte(s), nil
+	case "SET":
+		db.data[args[0]] = args[1]
+		delete(db.ttl, args[0])
+		if len(args) == 4 && strings.ToUpper(args[2]) == "PX" {
+			db.ttl[args[0]], _ = strconv.ParseInt(args[3], 10, 64)
+		}
+		return "OK", nil
+	case "DEL":
+		n := int64(0)
+		for _, key := range args {
+			if _, ok := db.data[key]; ok {
+				delete(db.data, key)
+				delete(db.ttl, key)
+				n++
+			}
+		}
+		return n, nil
+	case "TYPE":
+		switch db.data[args[0]].(type) {
+		case string:
+			return "string", nil
+		case map[string]string:
+			return "hash", nil
+		case map[string]bool:
+			return "set", nil
+		case []string:
+			return "list", nil
+		case fakeZSet:
+			return "zset", nil
+		}
+		return "none", nil
+	case "PTTL":
+		if _, ok := db.data[args[0]]; !ok {
+			return int64(-2), nil
+		}
+		if ttl, ok := db.ttl[args[0]]; ok {
+			return ttl, nil
+		}
+		return int64(-1), nil
+	case "PEXPIRE":
+		if _, ok := db.data[args[0]]; !ok {
+			return int64(0), nil
+		}
+		db.ttl[args[0]], _ = strconv.ParseInt(args[1], 10, 64)
+		return int64(1), nil
+	case "SADD":
+		set, ok := db.data[args[0]].(map[string]bool)
+		if !ok {
+			set = map[string]bool{}
+			db.data[args[0]] = set
+		}
+		n := int64(0)
+		for _, member := range args[1:] {
+			if !set[member] {
+				set[member] = true
+				n++
+			}
+		}
+		return n, nil
+	case "SMEMBERS":
+		set, _ := db.data[args[0]].(map[string]bool)
+		var reply []interface{}
+		for member := range set {
+			reply = append(reply, []byte(member))
+		}
+		return reply, nil
+	case "RPUSH":
+		list, _ := db.data[args[0]].([]string)
+		list = append(list, args[1:]...)
+		db.data[args[0]] = list
+		return int64(len(list)), nil
+	case "LRANGE":
+		// Only whole lists are supported
+		list, _ := db.data[args[0]].([]string)
+		var reply []interface{}
+		for _, value := range list {
+			reply = append(reply, []byte(value))
+		}
+		return reply, nil
+	case "SCAN":
+		var keys []interface{}
+		for key := range db.data {
+			if ok, _ := path.Match(args[2], key); ok {
+				keys = append(keys, []byte(key))
+			}
+		}
+		return []interface{}{[]byte("0"), keys}, nil
+	case "HGET":
+		h, _ := db.data[args[0]].(map[string]string)
+		v, ok := h[args[1]]
+		if !ok {
+			return nil, nil
+		}
+		return []byte(v), nil
+	case "HSET":
+		h := db.hash(args[0])
+		_, exists := h[args[1]]
+		h[args[1]] = args[2]
+		if exists {
+			return int64(0), nil
+		}
+		return int64(1), nil
+	case "HSETNX":
+		h := db.hash(args[0])
+		if _, exists := h[args[1]]; exists {
+			return int64(0), nil
+		}
+		h[args[1]] = args[2]
+		return int64(1), nil
+	case "HDEL":
+		h, _ := db.data[args[0]].(map[string]string)
+		n := int64(0)
+		for _, field := range args[1:] {
+			if _, ok := h[field]; ok {
+				delete(h, field)
+				n++
+			}
+		}
+		db.clean(args[0])
+		return n, nil
+	case "HGETALL":
+		h, _ := db.data[args[0]].(map[string]string)
+		var reply []interface{}
+		for field, value := range h {
+			reply = append(reply, []byte(field), []byte(value))
+		}
+		return reply, nil
+	case "ZADD":
+		z := db.zset(args[0])
+		n := int64(0)
+		for i := 1; i+1 < len(args); i += 2 {
+			score, err := strconv.ParseFloat(args[i], 64)
+			if err != nil {
+				return nil, redis.Error("ERR value is not a valid float")
+			}
+			if _, ok := z[args[i+1]]; !ok {
+				n++
+			}
+			z[args[i+1]] = score
+		}
+		return n, nil
+	case "ZREM":
+		z, _ := db.data[args[0]].(fakeZSet)
+		n := int64(0)
+		for _, member := range args[1:] {
+			if _, ok := z[member]; ok {
+				delete(z, member)
+				n++
+			}
+		}
+		db.clean(args[0])
+		return n, nil
+	case "ZRANGE":
+		z, _ := db.data[args[0]].(fakeZSet)
+		members := z.sorted()
+		start, _ := strconv.Atoi(args[1])
+		stop, _ := strconv.Atoi(args[2])
+		if start < 0 {
+			start += len(members)
+		}
+		if stop < 0 {
+			stop += len(members)
+		}
+		withScores := len(args) > 3 && strings.ToUpper(args[3]) == "WITHSCORES"
+		var reply []interface{}
+		for i := start; i >= 0 && i <= stop && i < len(members); i++ {
+			reply = append(reply, []byte(members[i]))
+			if withScores {
+				reply = append(reply, formatScore(z[members[i]]))
+			}
+		}
+		return reply, nil
+	case "ZRANGEBYSCORE":
+		z, _ := db.data[args[0]].(fakeZSet)
+		min, minExclusive, err := parseScore(args[1])
+		if err != nil {
+			return nil, err
+		}
+		max, maxExclusive, err := parseScore(args[2])
+		if err != nil {
+			return nil, err
+		}
+		withScores := len(args) > 3 && strings.ToUpper(args[3]) == "WITHSCORES"
+		var reply []interface{}
+		for _, m := range z.sorted() {
+			s := z[m]
+			if s < min || s > max || (minExclusive && s == min) || (maxExclusive && s == max) {
+				continue
+			}
+			reply = append(reply, []byte(m))
+			if withScores {
+				reply = append(reply, formatScore(s))
+			}
+		}
+		return reply, nil
+	}
+	return nil, fmt.Errorf("fakeRedis: unsupported command %s", cmd)
+}
+
+// fakeConn is a connection to a fakeRedis. Commands sent between MULTI and
+// EXEC are queued and run by EXEC; other sent commands run immediately and
+// their replies are kept for Receive.
+type fakeConn struct {
+	db      *fakeRedis
+	multi   bool
+	queued  [][]string
+	replies []interface{}
+}
+
+func fakeArgs(args []interface{}) []string {
+	s := make([]string, len(args))
+	for i, arg := range args {
+		switch arg := arg.(type) {
+		case []byte:
+			s[i] = string(arg)
+		case float64:
+			s[i] = string(formatScore(arg))
+		default:
+			s[i] = fmt.Sprint(arg)
+		}
+	}
+	return s
+}
+
+// Do runs cmd and discards the replies of sent commands, as Do flushes and
+// reads them on a real connection.
+func (c *fakeConn) Do(cmd string, args ...interface{}) (interface{}, error) {
+	c.replies = nil
+	return c.run(cmd, args...)
+}
+
+func (c *fakeConn) run(cmd string, args ...interface{}) (interface{}, error) {
+	cmd = strings.ToUpper(cmd)
+	switch cmd {
+	case "":
+		return nil, nil
+	case "MULTI":
+		c.multi = true
+		return "OK", nil
+	case "DISCARD":
+		c.multi, c.queued = false, nil
+		return "OK", nil
+	case "EXEC":
+		queued := c.queued
+		c.multi, c.queued = false, nil
+		replies := make([]interface{}, len(queued))
+		for i, q := range queued {
+			reply, err := c.db.do(q[0], q[1:])
+			if err != nil {
+				replies[i] = redis.Error(err.Error())
+			} else {
+				replies[i] = reply
+			}
+		}
+		return replies, nil
+	}
+	if c.multi {
+		c.queued = append(c.queued, append([]string{cmd}, fakeArgs(args)...))
+		return "QUEUED", nil
+	}
+	return c.db.do(cmd, fakeArgs(args))
+}
+
+func (c *fakeConn) Send(cmd string, args ...interface{}) error {
+	reply, err := c.run(cmd, args...)
+	if err != nil {
+		reply = redis.Error(err.Error())
+	}
+	c.replies = append(c.replies, reply)
+	return nil
+}
+
+func (c *fakeConn) Flush() error {
+	return nil
+}
+
+func (c *fakeConn) Receive() (interface{}, error) {
+	if len(c.replies) == 0 {
+		return nil, fmt.Errorf("fakeRedis: no pending replies")
+	}
+	reply := c.replies[0]
+	c.replies = c.replies[1:]
+	if err, ok := reply.(redis.Error); ok {
+		return nil, err
+	}
+	return reply, nil
+}
+
+func (c *fakeConn) Close() error {
+	return nil
+}
+
+func (c *fakeConn) Err() error {
+	return nil
+}
@@ -0,0 +1,35 @@
+package repository
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+// Locks are short-lived keys shared by every running instance of cpbot, such
+// as claims on reminders. They are left out of dumps.
+func (r *Redis) getLockKey(key string) string {
+	return fmt.Sprintf("%s:lock:%s", r.prefix, key)
+}
+
+func (r *Redis) getEventKey(id string) string {
+	return r.getLockKey("event:" + id)
+}
+
+// IsEventProcessed reports whether the webhook event id has been processed
+// by any instance within the last ttl given to MarkEventProcessed.
+func (r *Redis) IsEventProcessed(id string) (bool, error) {
+	conn := r.pool.Get()
+	defer conn.Close()
+	return redis.Bool(conn.Do("EXISTS", r.getEventKey(id)))
+}
+
+// MarkEventProcessed remembers that the webhook event id has been processed,
+// for ttl.
+func (r *Redis) MarkEventProcessed(id string, ttl time.Duration) error {
+	conn := r.pool.Get()
+	defer conn.Close()
+	_, err := conn.Do("SET", r.getEventKey(id), 1, "PX", int64(ttl/time.Millisecond))
+	return err
+}
@@ -0,0 +1,63 @@
+package repository
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+// Quotas are kept a little longer than a month, so that the usage of the
+// previous month can still be looked at.
+const quotaTTL = 40 * 24 * time.Hour
+
+func (r *Redis) getQuotaKey(period string) string {
+	return fmt.Sprintf("%s:quota:%s", r.prefix, period)
+}
+
+// reserveQuotaScript adds ARGV[1] to the usage, unless that would exceed the
+// limit ARGV[2], which is unlimited if it is not positive. It returns the
+// usage, and whether it has been added to.
+var reserveQuotaScript = redis.NewScript(1, `
+local used = tonumber(redis.call("GET", KEYS[1]) or "0")
+local limit = tonumber(ARGV[2])
+if limit > 0 and used + tonumber(ARGV[1]) > limit then
+	return {used, 0}
+end
+used = redis.call("INCRBY", KEYS[1], ARGV[1])
+redis.call("EXPIRE", KEYS[1], ARGV[3])
+return {used, 1}
+`)
+
+// ReserveQuota counts n against the quota of period, such as a month, unless
+// that would exceed limit. A limit that is not positive is unlimited. It
+// returns the usage of period, and whether n has been counted.
+func (r *Redis) ReserveQuota(period string, n, limit int) (int, bool, error) {
+	conn := r.pool.Get()
+	defer conn.Close()
+	res, err := redis.Ints(reserveQuotaScript.Do(conn, r.getQuotaKey(period), n, limit, int64(quotaTTL/time.Second)))
+	if err != nil {
+		return 0, false, err
+	}
+	return res[0], res[1] == 1, nil
+}
+
+// ReleaseQuota uncounts n reserved from the quota of period that ended up not
+// being used.
+func (r *Redis) ReleaseQuota(period string, n int) error {
+	conn := r.pool.Get()
+	defer conn.Close()
+	_, err := conn.Do("DECRBY", r.getQuotaKey(period), n)
+	return err
+}
+
+// GetQuotaUsed returns the usage of the quota of period.
+func (r *Redis) GetQuotaUsed(period string) (int, error) {
+	conn := r.pool.Get()
+	defer conn.Close()
+	used, err := redis.Int(conn.Do("GET", r.getQuotaKey(period)))
+	if err == redis.ErrNil {
+		return 0, nil
+	}
+	return used, err
+}
@@ -0,0 +1,30 @@
+package repository
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+func (r *Redis) getRateKey(key string) string {
+	return fmt.Sprintf("%s:rate:%s", r.prefix, key)
+}
+
+// countRateScript counts a hit of a window that expires ARGV[1] milliseconds
+// after its first hit.
+var countRateScript = redis.NewScript(1, `
+local n = redis.call("INCR", KEYS[1])
+if n == 1 then
+	redis.call("PEXPIRE", KEYS[1], ARGV[1])
+end
+return n
+`)
+
+// CountRate counts a hit of key, shared by every running instance of cpbot,
+// and returns the number of hits since the first one within window.
+func (r *Redis) CountRate(key string, window time.Duration) (int, error) {
+	conn := r.pool.Get()
+	defer conn.Close()
+	return redis.Int(countRateScript.Do(conn, r.getRateKey(key), int64(window/time.Millisecond)))
+}
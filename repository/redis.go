@@ -2,6 +2,8 @@ package repository
 
 import (
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/azaky/cpbot/util"
@@ -26,6 +28,14 @@ func NewRedis(prefix, addr string) *Redis {
 	}
 }
 
+// Ping checks the connection to Redis.
+func (r *Redis) Ping() error {
+	conn := r.pool.Get()
+	defer conn.Close()
+	_, err := conn.Do("PING")
+	return err
+}
+
 func (r *Redis) getUserKey() string {
 	return fmt.Sprintf("%s:users", r.prefix)
 }
@@ -42,68 +52,394 @@ func (r *Redis) RemoveUser(userID string) (interface{}, error) {
 	return conn.Do("SREM", r.getUserKey(), userID)
 }
 
+func (r *Redis) CountUsers() (int, error) {
+	conn := r.pool.Get()
+	defer conn.Close()
+	return redis.Int(conn.Do("SCARD", r.getUserKey()))
+}
+
 func (r *Redis) GetUsers() ([]string, error) {
 	conn := r.pool.Get()
 	defer conn.Close()
 	return redis.Strings(conn.Do("SMEMBERS", r.getUserKey()))
 }
 
-func (r *Redis) AddDaily(userID string, t int) (interface{}, error) {
+// Daily reminders are stored twice: in a global sorted set of "user@time"
+// members scored by their next occurrence (as a unix timestamp) for the
+// scheduler, and in a per-user sorted set for listing a user's reminder
+// times. Reminder times are wall clock times in the user's timezone, as
+// seconds of day.
+func dailyMember(userID string, t int) string {
+	return fmt.Sprintf("%s@%d", userID, t)
+}
+
+func (r *Redis) AddDaily(userID string, t int, next, now time.Time) (interface{}, error) {
+	conn := r.pool.Get()
+	defer conn.Close()
+	conn.Send("MULTI")
+	conn.Send("ZADD", r.getDailyKey(), next.Unix(), dailyMember(userID, t))
+	conn.Send("ZADD", r.getUserDailyKey(userID), t, t)
+	// Occurrences before the reminder is added are never delivered
+	conn.Send("HSETNX", r.getDailyDeliveredKey(), dailyMember(userID, t), now.Unix())
+	return conn.Do("EXEC")
+}
+
+// SetDailyNext sets the next occurrence of the reminder of userID at t, if
+// the reminder still exists.
+func (r *Redis) SetDailyNext(userID string, t int, next time.Time) (interface{}, error) {
 	conn := r.pool.Get()
 	defer conn.Close()
-	return conn.Do("ZADD", r.getDailyKey(), t, userID)
+	return conn.Do("ZADD", r.getDailyKey(), "XX", next.Unix(), dailyMember(userID, t))
 }
 
-func (r *Redis) RemoveDaily(userID string) (interface{}, error) {
+// RemoveDaily removes the reminder of userID at t, and reports whether it
+// existed.
+func (r *Redis) RemoveDaily(userID string, t int) (bool, error) {
 	conn := r.pool.Get()
 	defer conn.Close()
-	return conn.Do("ZREM", r.getDailyKey(), userID)
+	conn.Send("MULTI")
+	conn.Send("ZREM", r.getDailyKey(), dailyMember(userID, t))
+	conn.Send("ZREM", r.getUserDailyKey(userID), t)
+	conn.Send("HDEL", r.getDailyDeliveredKey(), dailyMember(userID, t))
+	reply, err := redis.Ints(conn.Do("EXEC"))
+	if err != nil {
+		return false, err
+	}
+	return reply[0] > 0, nil
 }
 
-func (r *Redis) GetDaily(userID string) (int, error) {
+func (r *Redis) RemoveAllDaily(userID string) (interface{}, error) {
+	times, err := r.GetDaily(userID)
+	if err != nil {
+		return nil, err
+	}
 	conn := r.pool.Get()
 	defer conn.Close()
-	return redis.Int(conn.Do("ZSCORE", r.getDailyKey(), userID))
+	conn.Send("MULTI")
+	for _, t := range times {
+		conn.Send("ZREM", r.getDailyKey(), dailyMember(userID, t))
+		conn.Send("HDEL", r.getDailyDeliveredKey(), dailyMember(userID, t))
+	}
+	conn.Send("DEL", r.getUserDailyKey(userID))
+	return conn.Do("EXEC")
+}
+
+func (r *Redis) GetDaily(userID string) ([]int, error) {
+	conn := r.pool.Get()
+	defer conn.Close()
+	return redis.Ints(conn.Do("ZRANGE", r.getUserDailyKey(userID), 0, -1))
 }
 
 type UserTime struct {
 	User string
 	Time int
+	Next time.Time
 }
 
 func (r *Redis) getDailyKey() string {
 	return fmt.Sprintf("%s:daily", r.prefix)
 }
 
-func (r *Redis) GetDailyWithin(from, to time.Time) ([]UserTime, error) {
-	ifrom := util.TimeToInt(from)
-	ito := util.TimeToInt(to)
-	// TODO: handle case middle of night
-	var res []UserTime
+func (r *Redis) getUserDailyKey(userID string) string {
+	return fmt.Sprintf("%s:daily:%s", r.prefix, userID)
+}
+
+// GetDailyUntil returns every reminder whose next occurrence is not after to,
+// including the ones that are overdue.
+func (r *Redis) GetDailyUntil(to time.Time) ([]UserTime, error) {
 	conn := r.pool.Get()
 	defer conn.Close()
-	reply, err := redis.Values(conn.Do("ZRANGEBYSCORE", r.getDailyKey(), ifrom, ito, "WITHSCORES"))
+	return scanDailyMembers(conn.Do("ZRANGEBYSCORE", r.getDailyKey(), "-inf", to.Unix(), "WITHSCORES"))
+}
+
+func (r *Redis) GetAllDaily() ([]UserTime, error) {
+	conn := r.pool.Get()
+	defer conn.Close()
+	return scanDailyMembers(conn.Do("ZRANGE", r.getDailyKey(), 0, -1, "WITHSCORES"))
+}
+
+type dailyEntry struct {
+	Member string
+	Score  int64
+}
+
+func scanDailyEntries(reply interface{}, err error) ([]dailyEntry, error) {
+	values, err := redis.Values(reply, err)
 	if err != nil {
 		return nil, err
 	}
-	err = redis.ScanSlice(reply, &res)
-	return res, err
+	var entries []dailyEntry
+	err = redis.ScanSlice(values, &entries)
+	return entries, err
+}
+
+// parseDailyMember splits a "user@time" member. Members of the oldest format
+// are plain users.
+func parseDailyMember(member string) (string, int, bool) {
+	i := strings.LastIndex(member, "@")
+	if i < 0 {
+		return member, 0, false
+	}
+	t, err := strconv.Atoi(member[i+1:])
+	if err != nil {
+		return member, 0, false
+	}
+	return member[:i], t, true
 }
 
-func (r *Redis) getTimezoneKey(user string) string {
-	return fmt.Sprintf("%s:timezone:%s", r.prefix, user)
+func scanDailyMembers(reply interface{}, err error) ([]UserTime, error) {
+	entries, err := scanDailyEntries(reply, err)
+	if err != nil {
+		return nil, err
+	}
+	var res []UserTime
+	for _, e := range entries {
+		user, t, ok := parseDailyMember(e.Member)
+		if !ok {
+			continue
+		}
+		res = append(res, UserTime{User: user, Time: t, Next: time.Unix(e.Score, 0)})
+	}
+	return res, nil
 }
 
-func (r *Redis) SetTimezone(user, tz string) (interface{}, error) {
+func (r *Redis) getDailyDeliveredKey() string {
+	return fmt.Sprintf("%s:dailydelivered", r.prefix)
+}
+
+func (r *Redis) getDailyClaimKey(userID string, t int, occurrence time.Time) string {
+	return r.getLockKey(fmt.Sprintf("daily:%s:%d", dailyMember(userID, t), occurrence.Unix()))
+}
+
+// claimDailyScript claims the delivery of an occurrence of a reminder for
+// ARGV[3] milliseconds, unless an occurrence at or after it has already been
+// delivered, or it has been claimed.
+var claimDailyScript = redis.NewScript(2, `
+local last = tonumber(redis.call("HGET", KEYS[1], ARGV[1]) or "0")
+if last >= tonumber(ARGV[2]) then
+	return 0
+end
+if not redis.call("SET", KEYS[2], "1", "NX", "PX", ARGV[3]) then
+	return 0
+end
+return 1
+`)
+
+// ClaimDaily claims the delivery of the occurrence of the reminder of userID
+// at t for ttl. It returns false if the occurrence has already been
+// delivered, or is being delivered, in which case it must not be delivered.
+// A claim lasts until CompleteDaily or ReleaseDaily, or until ttl passes, so
+// that the occurrence is delivered again if whoever claimed it is gone.
+func (r *Redis) ClaimDaily(userID string, t int, occurrence time.Time, ttl time.Duration) (bool, error) {
 	conn := r.pool.Get()
 	defer conn.Close()
-	return conn.Do("SET", r.getTimezoneKey(user), tz)
+	return redis.Bool(claimDailyScript.Do(conn, r.getDailyDeliveredKey(), r.getDailyClaimKey(userID, t, occurrence),
+		dailyMember(userID, t), occurrence.Unix(), int64(ttl/time.Millisecond)))
 }
 
-func (r *Redis) GetRawTimezone(user string) (string, error) {
+// completeDailyScript records the delivery of an occurrence of a reminder
+// and moves it on to its next occurrence, if the reminder still exists and
+// has not been moved past the delivered occurrence in the meantime.
+var completeDailyScript = redis.NewScript(3, `
+redis.call("DEL", KEYS[3])
+local score = redis.call("ZSCORE", KEYS[1], ARGV[1])
+if not score then
+	return 0
+end
+redis.call("HSET", KEYS[2], ARGV[1], ARGV[2])
+if tonumber(score) <= tonumber(ARGV[2]) then
+	redis.call("ZADD", KEYS[1], ARGV[3], ARGV[1])
+end
+return 1
+`)
+
+// CompleteDaily records that the claimed occurrence of the reminder of
+// userID at t has been delivered, and sets its next occurrence.
+func (r *Redis) CompleteDaily(userID string, t int, occurrence, next time.Time) error {
+	conn := r.pool.Get()
+	defer conn.Close()
+	_, err := completeDailyScript.Do(conn, r.getDailyKey(), r.getDailyDeliveredKey(), r.getDailyClaimKey(userID, t, occurrence),
+		dailyMember(userID, t), occurrence.Unix(), next.Unix())
+	return err
+}
+
+// ReleaseDaily gives up the claim of the occurrence of the reminder of
+// userID at t without delivering it, so that it can be delivered again.
+func (r *Redis) ReleaseDaily(userID string, t int, occurrence time.Time) error {
+	conn := r.pool.Get()
+	defer conn.Close()
+	_, err := conn.Do("DEL", r.getDailyClaimKey(userID, t, occurrence))
+	return err
+}
+
+// legacyDailyScoreLimit separates the scores of the old formats, which are
+// times of day in UTC, from next occurrences.
+const legacyDailyScoreLimit = 2 * 86400
+
+// MigrateDaily converts daily entries from the old formats, where reminder
+// times were stored as times of day in UTC (first as plain user members,
+// then as "user@time" members), into wall clock times in the user's
+// timezone scored by their next occurrence.
+func (r *Redis) MigrateDaily(now time.Time) (int, error) {
+	conn := r.pool.Get()
+	defer conn.Close()
+	entries, err := scanDailyEntries(conn.Do("ZRANGEBYSCORE", r.getDailyKey(), "-inf", fmt.Sprintf("(%d", legacyDailyScoreLimit), "WITHSCORES"))
+	if err != nil {
+		return 0, err
+	}
+	migrated := 0
+	for _, e := range entries {
+		user, _, _ := parseDailyMember(e.Member)
+		utc := int(e.Score)
+		tz, _ := r.GetTimezone(user)
+		t, next := migrateLegacyDaily(utc, tz, now)
+
+		conn.Send("MULTI")
+		conn.Send("ZREM", r.getDailyKey(), e.Member)
+		conn.Send("ZREM", r.getUserDailyKey(user), utc)
+		conn.Send("HDEL", r.getDailyDeliveredKey(), e.Member)
+		conn.Send("ZADD", r.getDailyKey(), next.Unix(), dailyMember(user, t))
+		conn.Send("ZADD", r.getUserDailyKey(user), t, t)
+		conn.Send("HSET", r.getDailyDeliveredKey(), dailyMember(user, t), now.Unix())
+		if _, err = conn.Do("EXEC"); err != nil {
+			return migrated, err
+		}
+		migrated++
+	}
+	return migrated, nil
+}
+
+// legacyDailyDate is the date whose UTC offset converted legacy reminder
+// times, as entered in the chat's timezone, into times of day in UTC.
+var legacyDailyDate = time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// migrateLegacyDaily converts utc, a reminder time stored as a time of day in
+// UTC, back into the wall clock time in tz it was entered as, and returns the
+// next occurrence of that wall clock time as of now.
+func migrateLegacyDaily(utc int, tz *time.Location, now time.Time) (int, time.Time) {
+	t := util.TimeToInt(legacyDailyDate.Add(time.Duration(utc) * time.Second).In(tz))
+	return t, util.NextTime(now, t, tz)
+}
+
+// Per-chat settings are kept as fields of a single hash per chat.
+const (
+	settingTimezone       = "timezone"
+	settingDailyWindow    = "dailywindow"
+	settingDailySkipEmpty = "dailyskipempty"
+	settingQuiet          = "quiet"
+)
+
+// Settings is the settings record of a chat. Fields that have not been set
+// are empty.
+type Settings struct {
+	Timezone       string `redis:"timezone"`
+	DailyWindow    string `redis:"dailywindow"`
+	DailySkipEmpty string `redis:"dailyskipempty"`
+	Quiet          string `redis:"quiet"`
+}
+
+func (r *Redis) getSettingsKey(user string) string {
+	return fmt.Sprintf("%s:settings:%s", r.prefix, user)
+}
+
+func (r *Redis) setSetting(user, field string, value interface{}) (interface{}, error) {
+	conn := r.pool.Get()
+	defer conn.Close()
+	return conn.Do("HSET", r.getSettingsKey(user), field, value)
+}
+
+// getSetting returns redis.ErrNil if the setting has not been set.
+func (r *Redis) getSetting(user, field string) (interface{}, error) {
+	conn := r.pool.Get()
+	defer conn.Close()
+	return conn.Do("HGET", r.getSettingsKey(user), field)
+}
+
+func (r *Redis) removeSetting(user, field string) (interface{}, error) {
+	conn := r.pool.Get()
+	defer conn.Close()
+	return conn.Do("HDEL", r.getSettingsKey(user), field)
+}
+
+func (r *Redis) GetSettings(user string) (Settings, error) {
+	conn := r.pool.Get()
+	defer conn.Close()
+	var settings Settings
+	reply, err := redis.Values(conn.Do("HGETALL", r.getSettingsKey(user)))
+	if err != nil {
+		return settings, err
+	}
+	err = redis.ScanStruct(reply, &settings)
+	return settings, err
+}
+
+// ResetSettings removes all settings of user. Daily reminder times are not
+// part of the settings record and are left untouched.
+func (r *Redis) ResetSettings(user string) (interface{}, error) {
+	conn := r.pool.Get()
+	defer conn.Close()
+	return conn.Do("DEL", r.getSettingsKey(user))
+}
+
+// MigrateSettings moves settings from the legacy per-setting keys, such as
+// "line:timezone:<user>", into the settings record of each user. Settings
+// that are already in the record are kept. It returns the number of legacy
+// keys migrated.
+func (r *Redis) MigrateSettings() (int, error) {
 	conn := r.pool.Get()
 	defer conn.Close()
-	return redis.String(conn.Do("GET", r.getTimezoneKey(user)))
+
+	migrated := 0
+	for _, field := range []string{settingTimezone, settingDailyWindow, settingDailySkipEmpty, settingQuiet} {
+		prefix := fmt.Sprintf("%s:%s:", r.prefix, field)
+		cursor := 0
+		for {
+			reply, err := redis.Values(conn.Do("SCAN", cursor, "MATCH", prefix+"*", "COUNT", 100))
+			if err != nil {
+				return migrated, err
+			}
+			cursor, _ = redis.Int(reply[0], nil)
+			keys, _ := redis.Strings(reply[1], nil)
+			for _, key := range keys {
+				value, err := redis.String(conn.Do("GET", key))
+				if err != nil {
+					continue
+				}
+				user := strings.TrimPrefix(key, prefix)
+				conn.Send("MULTI")
+				conn.Send("HSETNX", r.getSettingsKey(user), field, value)
+				conn.Send("DEL", key)
+				if _, err := conn.Do("EXEC"); err != nil {
+					return migrated, err
+				}
+				migrated++
+			}
+			if cursor == 0 {
+				break
+			}
+		}
+	}
+	return migrated, nil
+}
+
+// Migrate converts the repository from all older formats. Settings are
+// migrated first, as converting legacy daily entries needs the timezones of
+// their chats. It returns the number of settings and daily entries migrated.
+func (r *Redis) Migrate(now time.Time) (int, int, error) {
+	settings, err := r.MigrateSettings()
+	if err != nil {
+		return settings, 0, err
+	}
+	daily, err := r.MigrateDaily(now)
+	return settings, daily, err
+}
+
+func (r *Redis) SetTimezone(user, tz string) (interface{}, error) {
+	return r.setSetting(user, settingTimezone, tz)
+}
+
+func (r *Redis) GetRawTimezone(user string) (string, error) {
+	return redis.String(r.getSetting(user, settingTimezone))
 }
 
 func (r *Redis) GetTimezone(user string) (*time.Location, error) {
@@ -111,5 +447,184 @@ func (r *Redis) GetTimezone(user string) (*time.Location, error) {
 	if err != nil {
 		return time.UTC, err
 	}
-	return util.LoadLocation(tz)
+	loc, err := util.LoadLocation(tz)
+	if err != nil {
+		return time.UTC, err
+	}
+	return loc, nil
+}
+
+// Personal timezones belong to members rather than chats, so they are kept
+// apart from the settings of the member's own chat with the bot.
+func (r *Redis) getMyTimezoneKey(user string) string {
+	return fmt.Sprintf("%s:mytimezone:%s", r.prefix, user)
+}
+
+func (r *Redis) SetMyTimezone(user, tz string) (interface{}, error) {
+	conn := r.pool.Get()
+	defer conn.Close()
+	return conn.Do("SET", r.getMyTimezoneKey(user), tz)
+}
+
+func (r *Redis) GetRawMyTimezone(user string) (string, error) {
+	conn := r.pool.Get()
+	defer conn.Close()
+	return redis.String(conn.Do("GET", r.getMyTimezoneKey(user)))
+}
+
+func (r *Redis) GetMyTimezone(user string) (*time.Location, error) {
+	tz, err := r.GetRawMyTimezone(user)
+	if err != nil {
+		return time.UTC, err
+	}
+	loc, err := util.LoadLocation(tz)
+	if err != nil {
+		return time.UTC, err
+	}
+	return loc, nil
+}
+
+func (r *Redis) SetDailyWindow(user, window string) (interface{}, error) {
+	return r.setSetting(user, settingDailyWindow, window)
+}
+
+func (r *Redis) GetDailyWindow(user string) (string, error) {
+	return redis.String(r.getSetting(user, settingDailyWindow))
+}
+
+func (r *Redis) SetDailySkipEmpty(user string, skip bool) (interface{}, error) {
+	return r.setSetting(user, settingDailySkipEmpty, skip)
 }
+
+// GetDailySkipEmpty returns redis.ErrNil if user has never set the option.
+func (r *Redis) GetDailySkipEmpty(user string) (bool, error) {
+	return redis.Bool(r.getSetting(user, settingDailySkipEmpty))
+}
+
+func (r *Redis) SetQuiet(user, quiet string) (interface{}, error) {
+	return r.setSetting(user, settingQuiet, quiet)
+}
+
+func (r *Redis) RemoveQuiet(user string) (interface{}, error) {
+	return r.removeSetting(user, settingQuiet)
+}
+
+func (r *Redis) GetQuiet(user string) (string, error) {
+	return redis.String(r.getSetting(user, settingQuiet))
+}
+
+// Deferred messages are kept in a list per user, and the time they are due
+// is kept in a global sorted set so they can be picked up by the scheduler.
+func (r *Redis) getDeferredKey() string {
+	return fmt.Sprintf("%s:deferred", r.prefix)
+}
+
+func (r *Redis) getUserDeferredKey(user string) string {
+	return fmt.Sprintf("%s:deferred:%s", r.prefix, user)
+}
+
+type UserDue struct {
+	User string
+	Due  int64
+}
+
+func (r *Redis) DeferMessages(user string, due time.Time, messages ...string) (interface{}, error) {
+	conn := r.pool.Get()
+	defer conn.Close()
+	conn.Send("MULTI")
+	for _, message := range messages {
+		conn.Send("RPUSH", r.getUserDeferredKey(user), message)
+	}
+	conn.Send("ZADD", r.getDeferredKey(), due.Unix(), user)
+	return conn.Do("EXEC")
+}
+
+// PopDeferred returns and removes all deferred messages of user.
+func (r *Redis) PopDeferred(user string) ([]string, error) {
+	conn := r.pool.Get()
+	defer conn.Close()
+	conn.Send("MULTI")
+	conn.Send("LRANGE", r.getUserDeferredKey(user), 0, -1)
+	conn.Send("DEL", r.getUserDeferredKey(user))
+	conn.Send("ZREM", r.getDeferredKey(), user)
+	reply, err := redis.Values(conn.Do("EXEC"))
+	if err != nil {
+		return nil, err
+	}
+	return redis.Strings(reply[0], nil)
+}
+
+// ClearDeferred removes all deferred messages of user.
+func (r *Redis) ClearDeferred(user string) (interface{}, error) {
+	conn := r.pool.Get()
+	defer conn.Close()
+	conn.Send("MULTI")
+	conn.Send("DEL", r.getUserDeferredKey(user))
+	conn.Send("ZREM", r.getDeferredKey(), user)
+	return conn.Do("EXEC")
+}
+
+func (r *Redis) GetDeferredUntil(to time.Time) ([]UserDue, error) {
+	conn := r.pool.Get()
+	defer conn.Close()
+	reply, err := redis.Values(conn.Do("ZRANGEBYSCORE", r.getDeferredKey(), "-inf", to.Unix(), "WITHSCORES"))
+	if err != nil {
+		return nil, err
+	}
+	var res []UserDue
+	err = redis.ScanSlice(reply, &res)
+	return res, err
+}
+
+func (r *Redis) getAdminsKey(chat string) string {
+	return fmt.Sprintf("%s:admins:%s", r.prefix, chat)
+}
+
+func (r *Redis) AddAdmin(chat, userID string) (interface{}, error) {
+	conn := r.pool.Get()
+	defer conn.Close()
+	return conn.Do("SADD", r.getAdminsKey(chat), userID)
+}
+
+// RemoveAdmin removes userID from the admins of chat, unless it is the last
+// one. It reports whether userID has been removed.
+func (r *Redis) RemoveAdmin(chat, userID string) (bool, error) {
+	conn := r.pool.Get()
+	defer conn.Close()
+	return redis.Bool(removeAdminScript.Do(conn, r.getAdminsKey(chat), userID))
+}
+
+func (r *Redis) RemoveAdmins(chat string) (interface{}, error) {
+	conn := r.pool.Get()
+	defer conn.Close()
+	return conn.Do("DEL", r.getAdminsKey(chat))
+}
+
+func (r *Redis) GetAdmins(chat string) ([]string, error) {
+	conn := r.pool.Get()
+	defer conn.Close()
+	return redis.Strings(conn.Do("SMEMBERS", r.getAdminsKey(chat)))
+}
+
+// AuthorizeAdmin reports whether userID is an admin of chat. The first user
+// to be authorized in a chat without admins becomes its admin.
+func (r *Redis) AuthorizeAdmin(chat, userID string) (bool, error) {
+	conn := r.pool.Get()
+	defer conn.Close()
+	return redis.Bool(authorizeAdminScript.Do(conn, r.getAdminsKey(chat), userID))
+}
+
+var authorizeAdminScript = redis.NewScript(1, `
+if redis.call("SCARD", KEYS[1]) == 0 then
+	redis.call("SADD", KEYS[1], ARGV[1])
+	return 1
+end
+return redis.call("SISMEMBER", KEYS[1], ARGV[1])
+`)
+
+var removeAdminScript = redis.NewScript(1, `
+if redis.call("SISMEMBER", KEYS[1], ARGV[1]) == 0 or redis.call("SCARD", KEYS[1]) <= 1 then
+	return 0
+end
+return redis.call("SREM", KEYS[1], ARGV[1])
+`)
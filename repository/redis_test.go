@@ -0,0 +1,114 @@
+package repository
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseDailyMember(t *testing.T) {
+	tests := []struct {
+		member string
+		user   string
+		t      int
+		ok     bool
+	}{
+		{"user:U1@3600", "user:U1", 3600, true},
+		{"group:C1@0", "group:C1", 0, true},
+		// Members of the oldest format are plain users
+		{"user:U1", "user:U1", 0, false},
+		{"user:U1@x", "user:U1@x", 0, false},
+	}
+	for _, test := range tests {
+		user, tt, ok := parseDailyMember(test.member)
+		if user != test.user || tt != test.t || ok != test.ok {
+			t.Errorf("parseDailyMember(%q) = %q, %d, %t, want %q, %d, %t", test.member, user, tt, ok, test.user, test.t, test.ok)
+		}
+	}
+}
+
+func TestMigrateLegacyDaily(t *testing.T) {
+	jakarta, _ := time.LoadLocation("Asia/Jakarta")
+	newYork, _ := time.LoadLocation("America/New_York")
+	london, _ := time.LoadLocation("Europe/London")
+	summer := time.Date(2026, 7, 1, 12, 0, 0, 0, time.UTC)
+	winter := time.Date(2026, 12, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		utc  int
+		tz   *time.Location
+		now  time.Time
+		t    int
+		next time.Time
+	}{
+		{"utc", 9 * 3600, time.UTC, summer, 9 * 3600, time.Date(2026, 7, 2, 9, 0, 0, 0, time.UTC)},
+		{"ahead of utc", 2 * 3600, jakarta, summer, 9 * 3600, time.Date(2026, 7, 2, 2, 0, 0, 0, time.UTC)},
+		{"crossing midnight", 20 * 3600, jakarta, summer, 3 * 3600, time.Date(2026, 7, 1, 20, 0, 0, 0, time.UTC)},
+		// Entered as 08:00 in New York, in standard time as of 2017-01-01
+		{"behind utc, in daylight saving time", 13 * 3600, newYork, summer, 8 * 3600, time.Date(2026, 7, 1, 12, 0, 0, 0, time.UTC)},
+		{"behind utc, in standard time", 13 * 3600, newYork, winter, 8 * 3600, time.Date(2026, 12, 1, 13, 0, 0, 0, time.UTC)},
+		{"behind utc, crossing midnight", 2*3600 + 30*60, newYork, summer, 21*3600 + 30*60, time.Date(2026, 7, 2, 1, 30, 0, 0, time.UTC)},
+		{"seconds, in daylight saving time", 23*3600 + 59*60 + 59, london, summer, 23*3600 + 59*60 + 59, time.Date(2026, 7, 1, 22, 59, 59, 0, time.UTC)},
+	}
+	for _, test := range tests {
+		if test.utc >= legacyDailyScoreLimit {
+			t.Fatalf("%s: %d is not a legacy score", test.name, test.utc)
+		}
+		tt, next := migrateLegacyDaily(test.utc, test.tz, test.now)
+		if tt != test.t || !next.Equal(test.next) {
+			t.Errorf("%s: migrateLegacyDaily(%d, %s) = %d, %s, want %d, %s", test.name, test.utc, test.tz, tt, next.UTC(), test.t, test.next)
+		}
+	}
+	// Next occurrences are never scored as legacy times of day
+	if _, next := migrateLegacyDaily(0, time.UTC, summer); next.Unix() < legacyDailyScoreLimit {
+		t.Errorf("Next occurrence %d is scored as a legacy time of day", next.Unix())
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	r, db := newFakeRedis("line")
+	// A reminder at 09:00 in Jakarta, stored in the oldest format as 02:00
+	// UTC, with the timezone of the chat still in its legacy key
+	db.data["line:timezone:user:U1"] = "Asia/Jakarta"
+	db.data["line:daily"] = fakeZSet{"user:U1": 2 * 3600}
+	db.data["line:daily:user:U1"] = fakeZSet{"7200": 2 * 3600}
+	// A reminder at 08:00 in a chat without a timezone
+	db.data["line:daily"].(fakeZSet)["user:U2@28800"] = 8 * 3600
+	db.data["line:daily:user:U2"] = fakeZSet{"28800": 8 * 3600}
+
+	now := time.Date(2026, 7, 1, 12, 0, 0, 0, time.UTC)
+	settings, daily, err := r.Migrate(now)
+	if err != nil {
+		t.Fatalf("Migrate: %s", err)
+	}
+	if settings != 1 || daily != 2 {
+		t.Errorf("Migrate = %d, %d, want 1, 2", settings, daily)
+	}
+	if _, ok := db.data["line:timezone:user:U1"]; ok {
+		t.Errorf("Legacy timezone key was not removed")
+	}
+	if tz, _ := r.GetRawTimezone("user:U1"); tz != "Asia/Jakarta" {
+		t.Errorf("Timezone = %q, want Asia/Jakarta", tz)
+	}
+
+	want := map[string]time.Time{
+		"user:U1@32400": time.Date(2026, 7, 2, 2, 0, 0, 0, time.UTC),
+		"user:U2@28800": time.Date(2026, 7, 2, 8, 0, 0, 0, time.UTC),
+	}
+	z := db.data["line:daily"].(fakeZSet)
+	if len(z) != len(want) {
+		t.Errorf("Daily entries = %v, want %v", z, want)
+	}
+	for member, next := range want {
+		if score, ok := z[member]; !ok || int64(score) != next.Unix() {
+			t.Errorf("Daily entry %s = %v, %t, want %d", member, score, ok, next.Unix())
+		}
+	}
+	if times, _ := r.GetDaily("user:U1"); len(times) != 1 || times[0] != 9*3600 {
+		t.Errorf("Daily times of user:U1 = %v, want [%d]", times, 9*3600)
+	}
+
+	// Migrating again changes nothing
+	if settings, daily, err := r.Migrate(now.Add(time.Hour)); settings != 0 || daily != 0 || err != nil {
+		t.Errorf("Migrate again = %d, %d, %v, want 0, 0, nil", settings, daily, err)
+	}
+}
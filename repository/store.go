@@ -0,0 +1,49 @@
+package repository
+
+import "time"
+
+// DailyStore is the part of the repository that delivering daily reminders
+// and deferred messages depends on, shared by every running instance of
+// cpbot.
+type DailyStore interface {
+	GetDailyUntil(to time.Time) ([]UserTime, error)
+	SetDailyNext(userID string, t int, next time.Time) (interface{}, error)
+	ClaimDaily(userID string, t int, occurrence time.Time, ttl time.Duration) (bool, error)
+	CompleteDaily(userID string, t int, occurrence, next time.Time) error
+	ReleaseDaily(userID string, t int, occurrence time.Time) error
+
+	DeferMessages(user string, due time.Time, messages ...string) (interface{}, error)
+	PopDeferred(user string) ([]string, error)
+	GetDeferredUntil(to time.Time) ([]UserDue, error)
+
+	GetTimezone(user string) (*time.Location, error)
+	GetDailyWindow(user string) (string, error)
+	GetDailySkipEmpty(user string) (bool, error)
+	GetQuiet(user string) (string, error)
+}
+
+// QuotaStore counts usage against quotas shared by every running instance
+// of cpbot.
+type QuotaStore interface {
+	ReserveQuota(period string, n, limit int) (int, bool, error)
+	ReleaseQuota(period string, n int) error
+	GetQuotaUsed(period string) (int, error)
+}
+
+// EventStore remembers webhook events processed by every running instance
+// of cpbot, as Line may deliver an event more than once.
+type EventStore interface {
+	IsEventProcessed(id string) (bool, error)
+	MarkEventProcessed(id string, ttl time.Duration) error
+}
+
+// UserStore lists every chat cpbot is in.
+type UserStore interface {
+	GetUsers() ([]string, error)
+}
+
+// RateStore counts hits in windows shared by every running instance of
+// cpbot.
+type RateStore interface {
+	CountRate(key string, window time.Duration) (int, error)
+}
@@ -0,0 +1,169 @@
+package util
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock tells the time and schedules functions. Everything that depends on
+// the current time takes a Clock, so that it can be simulated with FakeClock.
+type Clock interface {
+	Now() time.Time
+	AfterFunc(d time.Duration, f func()) Timer
+	NewTicker(d time.Duration) Ticker
+}
+
+type Timer interface {
+	Stop() bool
+}
+
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+// RealClock is the Clock backed by the time package.
+var RealClock Clock = realClock{}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) AfterFunc(d time.Duration, f func()) Timer {
+	return time.AfterFunc(d, f)
+}
+
+func (realClock) NewTicker(d time.Duration) Ticker {
+	return realTicker{time.NewTicker(d)}
+}
+
+type realTicker struct {
+	*time.Ticker
+}
+
+func (t realTicker) C() <-chan time.Time {
+	return t.Ticker.C
+}
+
+// FakeClock is a Clock whose time only moves with Advance. Functions passed to
+// AfterFunc are run synchronously by Advance, in order of their time.
+type FakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []*fakeWaiter
+}
+
+type fakeWaiter struct {
+	clock  *FakeClock
+	at     time.Time
+	period time.Duration
+	f      func()
+	c      chan time.Time
+}
+
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *FakeClock) AfterFunc(d time.Duration, f func()) Timer {
+	return c.addWaiter(&fakeWaiter{clock: c, at: c.Now().Add(d), f: f})
+}
+
+func (c *FakeClock) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("non-positive interval for FakeClock.NewTicker")
+	}
+	return fakeTicker{c.addWaiter(&fakeWaiter{clock: c, at: c.Now().Add(d), period: d, c: make(chan time.Time, 1)})}
+}
+
+func (c *FakeClock) addWaiter(w *fakeWaiter) *fakeWaiter {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.waiters = append(c.waiters, w)
+	return w
+}
+
+func (c *FakeClock) removeWaiter(w *fakeWaiter) bool {
+	for i, waiter := range c.waiters {
+		if waiter == w {
+			c.waiters = append(c.waiters[:i], c.waiters[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Advance moves the time forward by d, firing every timer and ticker due on
+// the way.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	end := c.now.Add(d)
+	c.mu.Unlock()
+	c.AdvanceTo(end)
+}
+
+// AdvanceTo moves the time forward to end, firing every timer and ticker due
+// on the way.
+func (c *FakeClock) AdvanceTo(end time.Time) {
+	for {
+		c.mu.Lock()
+		var next *fakeWaiter
+		for _, w := range c.waiters {
+			if !w.at.After(end) && (next == nil || w.at.Before(next.at)) {
+				next = w
+			}
+		}
+		if next == nil {
+			if end.After(c.now) {
+				c.now = end
+			}
+			c.mu.Unlock()
+			return
+		}
+		if next.at.After(c.now) {
+			c.now = next.at
+		}
+		now := c.now
+		if next.period > 0 {
+			next.at = next.at.Add(next.period)
+		} else {
+			c.removeWaiter(next)
+		}
+		c.mu.Unlock()
+
+		if next.f != nil {
+			next.f()
+		} else {
+			select {
+			case next.c <- now:
+			default:
+			}
+		}
+	}
+}
+
+func (w *fakeWaiter) Stop() bool {
+	w.clock.mu.Lock()
+	defer w.clock.mu.Unlock()
+	return w.clock.removeWaiter(w)
+}
+
+type fakeTicker struct {
+	*fakeWaiter
+}
+
+func (t fakeTicker) C() <-chan time.Time {
+	return t.c
+}
+
+func (t fakeTicker) Stop() {
+	t.fakeWaiter.Stop()
+}
@@ -0,0 +1,105 @@
+package util
+
+import (
+	"testing"
+	"time"
+)
+
+var testStart = time.Date(2026, 3, 28, 22, 0, 0, 0, time.UTC)
+
+func TestFakeClockRunsTimersInOrder(t *testing.T) {
+	clock := NewFakeClock(testStart)
+	var fired []time.Time
+	record := func() { fired = append(fired, clock.Now()) }
+	clock.AfterFunc(3*time.Hour, record)
+	clock.AfterFunc(time.Hour, record)
+	clock.AfterFunc(2*time.Hour, func() {
+		record()
+		// Timers scheduled by timers fire within the same Advance
+		clock.AfterFunc(30*time.Minute, record)
+	})
+	stopped := clock.AfterFunc(90*time.Minute, record)
+	if !stopped.Stop() {
+		t.Error("Stop returned false for a pending timer")
+	}
+
+	clock.Advance(4 * time.Hour)
+	want := []time.Time{
+		testStart.Add(time.Hour),
+		testStart.Add(2 * time.Hour),
+		testStart.Add(150 * time.Minute),
+		testStart.Add(3 * time.Hour),
+	}
+	if len(fired) != len(want) {
+		t.Fatalf("Got timers fired at %v, want %v", fired, want)
+	}
+	for i := range want {
+		if !fired[i].Equal(want[i]) {
+			t.Errorf("Got timers fired at %v, want %v", fired, want)
+			break
+		}
+	}
+	if now := clock.Now(); !now.Equal(testStart.Add(4 * time.Hour)) {
+		t.Errorf("Got now %s, want %s", now, testStart.Add(4*time.Hour))
+	}
+	if stopped.Stop() {
+		t.Error("Stop returned true for a stopped timer")
+	}
+}
+
+func TestFakeClockTimerInThePastFiresOnNextAdvance(t *testing.T) {
+	clock := NewFakeClock(testStart)
+	fired := false
+	clock.AfterFunc(-time.Minute, func() { fired = true })
+	if fired {
+		t.Fatal("Timer fired before Advance")
+	}
+	clock.Advance(0)
+	if !fired {
+		t.Error("Timer in the past did not fire")
+	}
+	if !clock.Now().Equal(testStart) {
+		t.Errorf("Got now %s, want %s", clock.Now(), testStart)
+	}
+}
+
+func TestFakeClockTicksAcrossDays(t *testing.T) {
+	london, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock := NewFakeClock(testStart)
+	ticker := clock.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	// Three days in London, across midnight every day and the start of
+	// daylight saving time on March 29, 2026: the 29th only has 23 hours
+	hours := make(map[int]int)
+	for i := 1; i <= 72; i++ {
+		clock.Advance(time.Hour)
+		select {
+		case now := <-ticker.C():
+			if want := testStart.Add(time.Duration(i) * time.Hour); !now.Equal(want) {
+				t.Fatalf("Got tick at %s, want %s", now, want)
+			}
+			hours[now.In(london).Day()]++
+		default:
+			t.Fatalf("Did not tick after %d hours", i)
+		}
+	}
+	if hours[29] != 23 || hours[30] != 24 {
+		t.Errorf("Got %d ticks on March 29 and %d on March 30 in London, want 23 and 24", hours[29], hours[30])
+	}
+}
+
+func TestFakeClockStoppedTickerDoesNotTick(t *testing.T) {
+	clock := NewFakeClock(testStart)
+	ticker := clock.NewTicker(time.Minute)
+	ticker.Stop()
+	clock.Advance(time.Hour)
+	select {
+	case now := <-ticker.C():
+		t.Errorf("Stopped ticker ticked at %s", now)
+	default:
+	}
+}
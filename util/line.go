@@ -28,6 +28,16 @@ func LineEventSourceToString(es *linebot.EventSource) string {
 	return fmt.Sprintf("%s:%s", es.Type, LineEventSourceToReplyString(es))
 }
 
+// LineEventSenderToString returns the id of the user who triggered an event,
+// in the same format as LineEventSourceToString returns for a user source.
+// It is empty if the user is unknown.
+func LineEventSenderToString(es *linebot.EventSource) string {
+	if es.UserID == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s:%s", linebot.EventSourceTypeUser, es.UserID)
+}
+
 func StringToLineEventSource(s string) (*linebot.EventSource, error) {
 	matches := lineEventSourceRegex.FindStringSubmatch(s)
 	if matches == nil {
@@ -0,0 +1,419 @@
+package util
+
+// countryTimezones maps lowercased country names, as in the tz database
+// (iso3166.tab and zone.tab) along with common aliases, to their timezones.
+var countryTimezones = map[string][]string{
+	"afghanistan":                    {"Asia/Kabul"},
+	"albania":                        {"Europe/Tirane"},
+	"algeria":                        {"Africa/Algiers"},
+	"andorra":                        {"Europe/Andorra"},
+	"angola":                         {"Africa/Luanda"},
+	"anguilla":                       {"America/Anguilla"},
+	"antarctica":                     {"Antarctica/McMurdo", "Antarctica/Casey", "Antarctica/Davis", "Antarctica/DumontDUrville", "Antarctica/Mawson", "Antarctica/Palmer", "Antarctica/Rothera", "Antarctica/Syowa", "Antarctica/Troll", "Antarctica/Vostok"},
+	"antigua & barbuda":              {"America/Antigua"},
+	"antigua and barbuda":            {"America/Antigua"},
+	"argentina":                      {"America/Argentina/Buenos_Aires", "America/Argentina/Cordoba", "America/Argentina/Salta", "America/Argentina/Jujuy", "America/Argentina/Tucuman", "America/Argentina/Catamarca", "America/Argentina/La_Rioja", "America/Argentina/San_Juan", "America/Argentina/Mendoza", "America/Argentina/San_Luis", "America/Argentina/Rio_Gallegos", "America/Argentina/Ushuaia"},
+	"armenia":                        {"Asia/Yerevan"},
+	"aruba":                          {"America/Aruba"},
+	"australia":                      {"Australia/Lord_Howe", "Antarctica/Macquarie", "Australia/Hobart", "Australia/Melbourne", "Australia/Sydney", "Australia/Broken_Hill", "Australia/Brisbane", "Australia/Lindeman", "Australia/Adelaide", "Australia/Darwin", "Australia/Perth", "Australia/Eucla"},
+	"austria":                        {"Europe/Vienna"},
+	"azerbaijan":                     {"Asia/Baku"},
+	"bahamas":                        {"America/Nassau"},
+	"bahrain":                        {"Asia/Bahrain"},
+	"bangladesh":                     {"Asia/Dhaka"},
+	"barbados":                       {"America/Barbados"},
+	"belarus":                        {"Europe/Minsk"},
+	"belgium":                        {"Europe/Brussels"},
+	"belize":                         {"America/Belize"},
+	"benin":                          {"Africa/Porto-Novo"},
+	"bermuda":                        {"Atlantic/Bermuda"},
+	"bhutan":                         {"Asia/Thimphu"},
+	"bolivia":                        {"America/La_Paz"},
+	"bosnia & herzegovina":           {"Europe/Sarajevo"},
+	"bosnia and herzegovina":         {"Europe/Sarajevo"},
+	"botswana":                       {"Africa/Gaborone"},
+	"brazil":                         {"America/Noronha", "America/Belem", "America/Fortaleza", "America/Recife", "America/Araguaina", "America/Maceio", "America/Bahia", "America/Sao_Paulo", "America/Campo_Grande", "America/Cuiaba", "America/Santarem", "America/Porto_Velho", "America/Boa_Vista", "America/Manaus", "America/Eirunepe", "America/Rio_Branco"},
+	"britain (uk)":                   {"Europe/London"},
+	"britain":                        {"Europe/London"},
+	"uk":                             {"Europe/London"},
+	"british indian ocean territory": {"Indian/Chagos"},
+	"brunei":                         {"Asia/Brunei"},
+	"bulgaria":                       {"Europe/Sofia"},
+	"burkina faso":                   {"Africa/Ouagadougou"},
+	"burundi":                        {"Africa/Bujumbura"},
+	"cambodia":                       {"Asia/Phnom_Penh"},
+	"cameroon":                       {"Africa/Douala"},
+	"canada":                         {"America/St_Johns", "America/Halifax", "America/Glace_Bay", "America/Moncton", "America/Goose_Bay", "America/Blanc-Sablon", "America/Toronto", "America/Iqaluit", "America/Atikokan", "America/Winnipeg", "America/Resolute", "America/Rankin_Inlet", "America/Regina", "America/Swift_Current", "America/Edmonton", "America/Cambridge_Bay", "America/Inuvik", "America/Creston", "America/Dawson_Creek", "America/Fort_Nelson", "America/Whitehorse", "America/Dawson", "America/Vancouver"},
+	"cape verde":                     {"Atlantic/Cape_Verde"},
+	"caribbean nl":                   {"America/Kralendijk"},
+	"cayman islands":                 {"America/Cayman"},
+	"central african rep.":           {"Africa/Bangui"},
+	"chad":                           {"Africa/Ndjamena"},
+	"chile":                          {"America/Santiago", "America/Coyhaique", "America/Punta_Arenas", "Pacific/Easter"},
+	"china":                          {"Asia/Shanghai", "Asia/Urumqi"},
+	"christmas island":               {"Indian/Christmas"},
+	"cocos (keeling) islands":        {"Indian/Cocos"},
+	"colombia":                       {"America/Bogota"},
+	"comoros":                        {"Indian/Comoro"},
+	"congo (dem. rep.)":              {"Africa/Kinshasa", "Africa/Lubumbashi"},
+	"dem. rep. congo":                {"Africa/Kinshasa", "Africa/Lubumbashi"},
+	"congo":                          {"Africa/Kinshasa", "Africa/Lubumbashi", "Africa/Brazzaville"},
+	"congo (rep.)":                   {"Africa/Brazzaville"},
+	"rep. congo":                     {"Africa/Brazzaville"},
+	"cook islands":                   {"Pacific/Rarotonga"},
+	"costa rica":                     {"America/Costa_Rica"},
+	"croatia":                        {"Europe/Zagreb"},
+	"cuba":                           {"America/Havana"},
+	"curaçao":                        {"America/Curacao"},
+	"cyprus":                         {"Asia/Nicosia", "Asia/Famagusta"},
+	"czech republic":                 {"Europe/Prague"},
+	"côte d'ivoire":                  {"Africa/Abidjan"},
+	"denmark":                        {"Europe/Copenhagen"},
+	"djibouti":                       {"Africa/Djibouti"},
+	"dominica":                       {"America/Dominica"},
+	"dominican republic":             {"America/Santo_Domingo"},
+	"east timor":                     {"Asia/Dili"},
+	"ecuador":                        {"America/Guayaquil", "Pacific/Galapagos"},
+	"egypt":                          {"Africa/Cairo"},
+	"el salvador":                    {"America/El_Salvador"},
+	"equatorial guinea":              {"Africa/Malabo"},
+	"eritrea":                        {"Africa/Asmara"},
+	"estonia":                        {"Europe/Tallinn"},
+	"eswatini (swaziland)":           {"Africa/Mbabane"},
+	"eswatini":                       {"Africa/Mbabane"},
+	"swaziland":                      {"Africa/Mbabane"},
+	"ethiopia":                       {"Africa/Addis_Ababa"},
+	"falkland islands":               {"Atlantic/Stanley"},
+	"faroe islands":                  {"Atlantic/Faroe"},
+	"fiji":                           {"Pacific/Fiji"},
+	"finland":                        {"Europe/Helsinki"},
+	"france":                         {"Europe/Paris"},
+	"french guiana":                  {"America/Cayenne"},
+	"french polynesia":               {"Pacific/Tahiti", "Pacific/Marquesas", "Pacific/Gambier"},
+	"french s. terr.":                {"Indian/Kerguelen"},
+	"gabon":                          {"Africa/Libreville"},
+	"gambia":                         {"Africa/Banjul"},
+	"georgia":                        {"Asia/Tbilisi"},
+	"germany":                        {"Europe/Berlin", "Europe/Busingen"},
+	"ghana":                          {"Africa/Accra"},
+	"gibraltar":                      {"Europe/Gibraltar"},
+	"greece":                         {"Europe/Athens"},
+	"greenland":                      {"America/Nuuk", "America/Danmarkshavn", "America/Scoresbysund", "America/Thule"},
+	"grenada":                        {"America/Grenada"},
+	"guadeloupe":                     {"America/Guadeloupe"},
+	"guam":                           {"Pacific/Guam"},
+	"guatemala":                      {"America/Guatemala"},
+	"guernsey":                       {"Europe/Guernsey"},
+	"guinea":                         {"Africa/Conakry"},
+	"guinea-bissau":                  {"Africa/Bissau"},
+	"guyana":                         {"America/Guyana"},
+	"haiti":                          {"America/Port-au-Prince"},
+	"honduras":                       {"America/Tegucigalpa"},
+	"hong kong":                      {"Asia/Hong_Kong"},
+	"hungary":                        {"Europe/Budapest"},
+	"iceland":                        {"Atlantic/Reykjavik"},
+	"india":                          {"Asia/Kolkata"},
+	"indonesia":                      {"Asia/Jakarta", "Asia/Pontianak", "Asia/Makassar", "Asia/Jayapura"},
+	"iran":                           {"Asia/Tehran"},
+	"iraq":                           {"Asia/Baghdad"},
+	"ireland":                        {"Europe/Dublin"},
+	"isle of man":                    {"Europe/Isle_of_Man"},
+	"israel":                         {"Asia/Jerusalem"},
+	"italy":                          {"Europe/Rome"},
+	"jamaica":                        {"America/Jamaica"},
+	"japan":                          {"Asia/Tokyo"},
+	"jersey":                         {"Europe/Jersey"},
+	"jordan":                         {"Asia/Amman"},
+	"kazakhstan":                     {"Asia/Almaty", "Asia/Qyzylorda", "Asia/Qostanay", "Asia/Aqtobe", "Asia/Aqtau", "Asia/Atyrau", "Asia/Oral"},
+	"kenya":                          {"Africa/Nairobi"},
+	"kiribati":                       {"Pacific/Tarawa", "Pacific/Kanton", "Pacific/Kiritimati"},
+	"korea (north)":                  {"Asia/Pyongyang"},
+	"north korea":                    {"Asia/Pyongyang"},
+	"korea":                          {"Asia/Pyongyang", "Asia/Seoul"},
+	"korea (south)":                  {"Asia/Seoul"},
+	"south korea":                    {"Asia/Seoul"},
+	"kuwait":                         {"Asia/Kuwait"},
+	"kyrgyzstan":                     {"Asia/Bishkek"},
+	"laos":                           {"Asia/Vientiane"},
+	"latvia":                         {"Europe/Riga"},
+	"lebanon":                        {"Asia/Beirut"},
+	"lesotho":                        {"Africa/Maseru"},
+	"liberia":                        {"Africa/Monrovia"},
+	"libya":                          {"Africa/Tripoli"},
+	"liechtenstein":                  {"Europe/Vaduz"},
+	"lithuania":                      {"Europe/Vilnius"},
+	"luxembourg":                     {"Europe/Luxembourg"},
+	"macau":                          {"Asia/Macau"},
+	"madagascar":                     {"Indian/Antananarivo"},
+	"malawi":                         {"Africa/Blantyre"},
+	"malaysia":                       {"Asia/Kuala_Lumpur", "Asia/Kuching"},
+	"maldives":                       {"Indian/Maldives"},
+	"mali":                           {"Africa/Bamako"},
+	"malta":                          {"Europe/Malta"},
+	"marshall islands":               {"Pacific/Majuro", "Pacific/Kwajalein"},
+	"martinique":                     {"America/Martinique"},
+	"mauritania":                     {"Africa/Nouakchott"},
+	"mauritius":                      {"Indian/Mauritius"},
+	"mayotte":                        {"Indian/Mayotte"},
+	"mexico":                         {"America/Mexico_City", "America/Cancun", "America/Merida", "America/Monterrey", "America/Matamoros", "America/Chihuahua", "America/Ciudad_Juarez", "America/Ojinaga", "America/Mazatlan", "America/Bahia_Banderas", "America/Hermosillo", "America/Tijuana"},
+	"micronesia":                     {"Pacific/Chuuk", "Pacific/Pohnpei", "Pacific/Kosrae"},
+	"moldova":                        {"Europe/Chisinau"},
+	"monaco":                         {"Europe/Monaco"},
+	"mongolia":                       {"Asia/Ulaanbaatar", "Asia/Hovd"},
+	"montenegro":                     {"Europe/Podgorica"},
+	"montserrat":                     {"America/Montserrat"},
+	"morocco":                        {"Africa/Casablanca"},
+	"mozambique":                     {"Africa/Maputo"},
+	"myanmar (burma)":                {"Asia/Yangon"},
+	"myanmar":                        {"Asia/Yangon"},
+	"burma":                          {"Asia/Yangon"},
+	"namibia":                        {"Africa/Windhoek"},
+	"nauru":                          {"Pacific/Nauru"},
+	"nepal":                          {"Asia/Kathmandu"},
+	"netherlands":                    {"Europe/Amsterdam"},
+	"new caledonia":                  {"Pacific/Noumea"},
+	"new zealand":                    {"Pacific/Auckland", "Pacific/Chatham"},
+	"nicaragua":                      {"America/Managua"},
+	"niger":                          {"Africa/Niamey"},
+	"nigeria":                        {"Africa/Lagos"},
+	"niue":                           {"Pacific/Niue"},
+	"norfolk island":                 {"Pacific/Norfolk"},
+	"north macedonia":                {"Europe/Skopje"},
+	"northern mariana islands":       {"Pacific/Saipan"},
+	"norway":                         {"Europe/Oslo"},
+	"oman":                           {"Asia/Muscat"},
+	"pakistan":                       {"Asia/Karachi"},
+	"palau":                          {"Pacific/Palau"},
+	"palestine":                      {"Asia/Gaza", "Asia/Hebron"},
+	"panama":                         {"America/Panama"},
+	"papua new guinea":               {"Pacific/Port_Moresby", "Pacific/Bougainville"},
+	"paraguay":                       {"America/Asuncion"},
+	"peru":                           {"America/Lima"},
+	"philippines":                    {"Asia/Manila"},
+	"pitcairn":                       {"Pacific/Pitcairn"},
+	"poland":                         {"Europe/Warsaw"},
+	"portugal":                       {"Europe/Lisbon", "Atlantic/Madeira", "Atlantic/Azores"},
+	"puerto rico":                    {"America/Puerto_Rico"},
+	"qatar":                          {"Asia/Qatar"},
+	"romania":                        {"Europe/Bucharest"},
+	"russia":                         {"Europe/Kaliningrad", "Europe/Moscow", "Europe/Kirov", "Europe/Volgograd", "Europe/Astrakhan", "Europe/Saratov", "Europe/Ulyanovsk", "Europe/Samara", "Asia/Yekaterinburg", "Asia/Omsk", "Asia/Novosibirsk", "Asia/Barnaul", "Asia/Tomsk", "Asia/Novokuznetsk", "Asia/Krasnoyarsk", "Asia/Irkutsk", "Asia/Chita", "Asia/Yakutsk", "Asia/Khandyga", "Asia/Vladivostok", "Asia/Ust-Nera", "Asia/Magadan", "Asia/Sakhalin", "Asia/Srednekolymsk", "Asia/Kamchatka", "Asia/Anadyr"},
+	"rwanda":                         {"Africa/Kigali"},
+	"réunion":                        {"Indian/Reunion"},
+	"samoa (american)":               {"Pacific/Pago_Pago"},
+	"samoa (western)":                {"Pacific/Apia"},
+	"san marino":                     {"Europe/San_Marino"},
+	"sao tome & principe":            {"Africa/Sao_Tome"},
+	"sao tome and principe":          {"Africa/Sao_Tome"},
+	"saudi arabia":                   {"Asia/Riyadh"},
+	"senegal":                        {"Africa/Dakar"},
+	"serbia":                         {"Europe/Belgrade"},
+	"seychelles":                     {"Indian/Mahe"},
+	"sierra leone":                   {"Africa/Freetown"},
+	"singapore":                      {"Asia/Singapore"},
+	"slovakia":                       {"Europe/Bratislava"},
+	"slovenia":                       {"Europe/Ljubljana"},
+	"solomon islands":                {"Pacific/Guadalcanal"},
+	"somalia":                        {"Africa/Mogadishu"},
+	"south africa":                   {"Africa/Johannesburg"},
+	"south georgia & the south sandwich islands":   {"Atlantic/South_Georgia"},
+	"south georgia and the south sandwich islands": {"Atlantic/South_Georgia"},
+	"south sudan":               {"Africa/Juba"},
+	"spain":                     {"Europe/Madrid", "Africa/Ceuta", "Atlantic/Canary"},
+	"sri lanka":                 {"Asia/Colombo"},
+	"st barthelemy":             {"America/St_Barthelemy"},
+	"st helena":                 {"Atlantic/St_Helena"},
+	"st kitts & nevis":          {"America/St_Kitts"},
+	"st kitts and nevis":        {"America/St_Kitts"},
+	"st lucia":                  {"America/St_Lucia"},
+	"st maarten (dutch)":        {"America/Lower_Princes"},
+	"st martin (french)":        {"America/Marigot"},
+	"st pierre & miquelon":      {"America/Miquelon"},
+	"st pierre and miquelon":    {"America/Miquelon"},
+	"st vincent":                {"America/St_Vincent"},
+	"sudan":                     {"Africa/Khartoum"},
+	"suriname":                  {"America/Paramaribo"},
+	"svalbard & jan mayen":      {"Arctic/Longyearbyen"},
+	"svalbard and jan mayen":    {"Arctic/Longyearbyen"},
+	"sweden":                    {"Europe/Stockholm"},
+	"switzerland":               {"Europe/Zurich"},
+	"syria":                     {"Asia/Damascus"},
+	"taiwan":                    {"Asia/Taipei"},
+	"tajikistan":                {"Asia/Dushanbe"},
+	"tanzania":                  {"Africa/Dar_es_Salaam"},
+	"thailand":                  {"Asia/Bangkok"},
+	"togo":                      {"Africa/Lome"},
+	"tokelau":                   {"Pacific/Fakaofo"},
+	"tonga":                     {"Pacific/Tongatapu"},
+	"trinidad & tobago":         {"America/Port_of_Spain"},
+	"trinidad and tobago":       {"America/Port_of_Spain"},
+	"tunisia":                   {"Africa/Tunis"},
+	"turkey":                    {"Europe/Istanbul"},
+	"turkmenistan":              {"Asia/Ashgabat"},
+	"turks & caicos is":         {"America/Grand_Turk"},
+	"turks and caicos is":       {"America/Grand_Turk"},
+	"tuvalu":                    {"Pacific/Funafuti"},
+	"uganda":                    {"Africa/Kampala"},
+	"ukraine":                   {"Europe/Simferopol", "Europe/Kyiv"},
+	"united arab emirates":      {"Asia/Dubai"},
+	"united states":             {"America/New_York", "America/Detroit", "America/Kentucky/Louisville", "America/Kentucky/Monticello", "America/Indiana/Indianapolis", "America/Indiana/Vincennes", "America/Indiana/Winamac", "America/Indiana/Marengo", "America/Indiana/Petersburg", "America/Indiana/Vevay", "America/Chicago", "America/Indiana/Tell_City", "America/Indiana/Knox", "America/Menominee", "America/North_Dakota/Center", "America/North_Dakota/New_Salem", "America/North_Dakota/Beulah", "America/Denver", "America/Boise", "America/Phoenix", "America/Los_Angeles", "America/Anchorage", "America/Juneau", "America/Sitka", "America/Metlakatla", "America/Yakutat", "America/Nome", "America/Adak", "Pacific/Honolulu"},
+	"uruguay":                   {"America/Montevideo"},
+	"us minor outlying islands": {"Pacific/Midway", "Pacific/Wake"},
+	"uzbekistan":                {"Asia/Samarkand", "Asia/Tashkent"},
+	"vanuatu":                   {"Pacific/Efate"},
+	"vatican city":              {"Europe/Vatican"},
+	"venezuela":                 {"America/Caracas"},
+	"vietnam":                   {"Asia/Ho_Chi_Minh"},
+	"virgin islands (uk)":       {"America/Tortola"},
+	"virgin islands (us)":       {"America/St_Thomas"},
+	"wallis & futuna":           {"Pacific/Wallis"},
+	"wallis and futuna":         {"Pacific/Wallis"},
+	"western sahara":            {"Africa/El_Aaiun"},
+	"yemen":                     {"Asia/Aden"},
+	"zambia":                    {"Africa/Lusaka"},
+	"zimbabwe":                  {"Africa/Harare"},
+	"åland islands":             {"Europe/Mariehamn"},
+	"usa":                       {"America/New_York", "America/Detroit", "America/Kentucky/Louisville", "America/Kentucky/Monticello", "America/Indiana/Indianapolis", "America/Indiana/Vincennes", "America/Indiana/Winamac", "America/Indiana/Marengo", "America/Indiana/Petersburg", "America/Indiana/Vevay", "America/Chicago", "America/Indiana/Tell_City", "America/Indiana/Knox", "America/Menominee", "America/North_Dakota/Center", "America/North_Dakota/New_Salem", "America/North_Dakota/Beulah", "America/Denver", "America/Boise", "America/Phoenix", "America/Los_Angeles", "America/Anchorage", "America/Juneau", "America/Sitka", "America/Metlakatla", "America/Yakutat", "America/Nome", "America/Adak", "Pacific/Honolulu"},
+	"united states of america":  {"America/New_York", "America/Detroit", "America/Kentucky/Louisville", "America/Kentucky/Monticello", "America/Indiana/Indianapolis", "America/Indiana/Vincennes", "America/Indiana/Winamac", "America/Indiana/Marengo", "America/Indiana/Petersburg", "America/Indiana/Vevay", "America/Chicago", "America/Indiana/Tell_City", "America/Indiana/Knox", "America/Menominee", "America/North_Dakota/Center", "America/North_Dakota/New_Salem", "America/North_Dakota/Beulah", "America/Denver", "America/Boise", "America/Phoenix", "America/Los_Angeles", "America/Anchorage", "America/Juneau", "America/Sitka", "America/Metlakatla", "America/Yakutat", "America/Nome", "America/Adak", "Pacific/Honolulu"},
+	"america":                   {"America/New_York", "America/Detroit", "America/Kentucky/Louisville", "America/Kentucky/Monticello", "America/Indiana/Indianapolis", "America/Indiana/Vincennes", "America/Indiana/Winamac", "America/Indiana/Marengo", "America/Indiana/Petersburg", "America/Indiana/Vevay", "America/Chicago", "America/Indiana/Tell_City", "America/Indiana/Knox", "America/Menominee", "America/North_Dakota/Center", "America/North_Dakota/New_Salem", "America/North_Dakota/Beulah", "America/Denver", "America/Boise", "America/Phoenix", "America/Los_Angeles", "America/Anchorage", "America/Juneau", "America/Sitka", "America/Metlakatla", "America/Yakutat", "America/Nome", "America/Adak", "Pacific/Honolulu"},
+	"united kingdom":            {"Europe/London"},
+	"great britain":             {"Europe/London"},
+	"england":                   {"Europe/London"},
+	"scotland":                  {"Europe/London"},
+	"wales":                     {"Europe/London"},
+	"viet nam":                  {"Asia/Ho_Chi_Minh"},
+	"czechia":                   {"Europe/Prague"},
+	"timor-leste":               {"Asia/Dili"},
+	"holland":                   {"Europe/Amsterdam"},
+	"uae":                       {"Asia/Dubai"},
+	"ivory coast":               {"Africa/Abidjan"},
+	"cote d'ivoire":             {"Africa/Abidjan"},
+	"hongkong":                  {"Asia/Hong_Kong"},
+	"macao":                     {"Asia/Macau"},
+	"turkiye":                   {"Europe/Istanbul"},
+	"drc":                       {"Africa/Kinshasa", "Africa/Lubumbashi"},
+	"vatican":                   {"Europe/Vatican"},
+	"cabo verde":                {"Atlantic/Cape_Verde"},
+	"reunion":                   {"Indian/Reunion"},
+	"curacao":                   {"America/Curacao"},
+}
+
+// cityTimezones maps lowercased names of cities that are not part of any
+// timezone name to their timezones. Cities in timezone names (e.g. Tokyo in
+// Asia/Tokyo) are found from timezoneNames instead.
+var cityTimezones = map[string]string{
+	"abu dhabi":        "Asia/Dubai",
+	"ambon":            "Asia/Jayapura",
+	"ankara":           "Europe/Istanbul",
+	"atlanta":          "America/New_York",
+	"auckland":         "Pacific/Auckland",
+	"austin":           "America/Chicago",
+	"bali":             "Asia/Makassar",
+	"balikpapan":       "Asia/Makassar",
+	"bandung":          "Asia/Jakarta",
+	"bangalore":        "Asia/Kolkata",
+	"banjarmasin":      "Asia/Makassar",
+	"barcelona":        "Europe/Madrid",
+	"beijing":          "Asia/Shanghai",
+	"bekasi":           "Asia/Jakarta",
+	"bengaluru":        "Asia/Kolkata",
+	"bern":             "Europe/Zurich",
+	"bogor":            "Asia/Jakarta",
+	"boston":           "America/New_York",
+	"brasilia":         "America/Sao_Paulo",
+	"busan":            "Asia/Seoul",
+	"calgary":          "America/Edmonton",
+	"cambridge":        "Europe/London",
+	"campinas":         "America/Sao_Paulo",
+	"canberra":         "Australia/Sydney",
+	"cape town":        "Africa/Johannesburg",
+	"cebu":             "Asia/Manila",
+	"chengdu":          "Asia/Shanghai",
+	"chennai":          "Asia/Kolkata",
+	"chiang mai":       "Asia/Bangkok",
+	"cologne":          "Europe/Berlin",
+	"da nang":          "Asia/Ho_Chi_Minh",
+	"daejeon":          "Asia/Seoul",
+	"dallas":           "America/Chicago",
+	"delhi":            "Asia/Kolkata",
+	"denpasar":         "Asia/Makassar",
+	"depok":            "Asia/Jakarta",
+	"edinburgh":        "Europe/London",
+	"frankfurt":        "Europe/Berlin",
+	"fukuoka":          "Asia/Tokyo",
+	"geneva":           "Europe/Zurich",
+	"guadalajara":      "America/Mexico_City",
+	"guangzhou":        "Asia/Shanghai",
+	"hamburg":          "Europe/Berlin",
+	"hangzhou":         "Asia/Shanghai",
+	"hanoi":            "Asia/Ho_Chi_Minh",
+	"houston":          "America/Chicago",
+	"hsinchu":          "Asia/Taipei",
+	"hyderabad":        "Asia/Kolkata",
+	"istanbul":         "Europe/Istanbul",
+	"jogja":            "Asia/Jakarta",
+	"johor bahru":      "Asia/Kuala_Lumpur",
+	"kaohsiung":        "Asia/Taipei",
+	"kharkiv":          "Europe/Kyiv",
+	"kharkov":          "Europe/Kyiv",
+	"krakow":           "Europe/Warsaw",
+	"kyoto":            "Asia/Tokyo",
+	"las vegas":        "America/Los_Angeles",
+	"lyon":             "Europe/Paris",
+	"malang":           "Asia/Jakarta",
+	"manado":           "Asia/Makassar",
+	"manchester":       "Europe/London",
+	"mataram":          "Asia/Makassar",
+	"medan":            "Asia/Jakarta",
+	"miami":            "America/New_York",
+	"milan":            "Europe/Rome",
+	"minneapolis":      "America/Chicago",
+	"minsk":            "Europe/Minsk",
+	"monterrey":        "America/Monterrey",
+	"montreal":         "America/Toronto",
+	"mumbai":           "Asia/Kolkata",
+	"munich":           "Europe/Berlin",
+	"nagoya":           "Asia/Tokyo",
+	"new delhi":        "Asia/Kolkata",
+	"novosibirsk":      "Asia/Novosibirsk",
+	"osaka":            "Asia/Tokyo",
+	"ottawa":           "America/Toronto",
+	"oxford":           "Europe/London",
+	"padang":           "Asia/Jakarta",
+	"palembang":        "Asia/Jakarta",
+	"penang":           "Asia/Kuala_Lumpur",
+	"philadelphia":     "America/New_York",
+	"phuket":           "Asia/Bangkok",
+	"pittsburgh":       "America/New_York",
+	"portland":         "America/Los_Angeles",
+	"porto":            "Europe/Lisbon",
+	"pretoria":         "Africa/Johannesburg",
+	"pune":             "Asia/Kolkata",
+	"quezon city":      "Asia/Manila",
+	"rio de janeiro":   "America/Sao_Paulo",
+	"rotterdam":        "Europe/Amsterdam",
+	"saigon":           "Asia/Ho_Chi_Minh",
+	"saint petersburg": "Europe/Moscow",
+	"salt lake city":   "America/Denver",
+	"san diego":        "America/Los_Angeles",
+	"san francisco":    "America/Los_Angeles",
+	"san jose":         "America/Los_Angeles",
+	"sapporo":          "Asia/Tokyo",
+	"seattle":          "America/Los_Angeles",
+	"semarang":         "Asia/Jakarta",
+	"shenzhen":         "Asia/Shanghai",
+	"silicon valley":   "America/Los_Angeles",
+	"solo":             "Asia/Jakarta",
+	"st petersburg":    "Europe/Moscow",
+	"surabaya":         "Asia/Jakarta",
+	"surakarta":        "Asia/Jakarta",
+	"taichung":         "Asia/Taipei",
+	"tangerang":        "Asia/Jakarta",
+	"tel aviv":         "Asia/Jerusalem",
+	"the hague":        "Europe/Amsterdam",
+	"washington":       "America/New_York",
+	"washington dc":    "America/New_York",
+	"waterloo":         "America/Toronto",
+	"wellington":       "Pacific/Auckland",
+	"wuhan":            "Asia/Shanghai",
+	"yogyakarta":       "Asia/Jakarta",
+}
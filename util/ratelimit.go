@@ -0,0 +1,58 @@
+package util
+
+import (
+	"sync"
+	"time"
+)
+
+// TokenBucket limits the rate of something to rate per second on average,
+// allowing bursts of up to burst at once.
+type TokenBucket struct {
+	clock  Clock
+	rate   float64
+	burst  float64
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+// NewTokenBucket returns a TokenBucket that starts full.
+func NewTokenBucket(clock Clock, rate float64, burst int) *TokenBucket {
+	return &TokenBucket{
+		clock:  clock,
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   clock.Now(),
+	}
+}
+
+// Reserve takes a token, and returns how long to wait before using it.
+func (t *TokenBucket) Reserve() time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := t.clock.Now()
+	if elapsed := now.Sub(t.last).Seconds(); elapsed > 0 {
+		t.tokens += elapsed * t.rate
+		if t.tokens > t.burst {
+			t.tokens = t.burst
+		}
+		t.last = now
+	}
+	t.tokens--
+	if t.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-t.tokens / t.rate * float64(time.Second))
+}
+
+// Wait takes a token, waiting until it can be used.
+func (t *TokenBucket) Wait() {
+	d := t.Reserve()
+	if d <= 0 {
+		return
+	}
+	done := make(chan struct{})
+	t.clock.AfterFunc(d, func() { close(done) })
+	<-done
+}
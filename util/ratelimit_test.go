@@ -0,0 +1,66 @@
+package util
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTokenBucketReserve(t *testing.T) {
+	clock := NewFakeClock(testStart)
+	bucket := NewTokenBucket(clock, 2, 3)
+
+	// Starts full
+	for i := 0; i < 3; i++ {
+		if d := bucket.Reserve(); d != 0 {
+			t.Fatalf("Got wait %s for token %d of a full bucket, want 0", d, i)
+		}
+	}
+	// Then tokens are reserved ahead, at 2 per second
+	for _, want := range []time.Duration{500 * time.Millisecond, time.Second} {
+		if d := bucket.Reserve(); d != want {
+			t.Errorf("Got wait %s, want %s", d, want)
+		}
+	}
+
+	clock.Advance(time.Second)
+	if d := bucket.Reserve(); d != 500*time.Millisecond {
+		t.Errorf("Got wait %s after a second, want 500ms", d)
+	}
+
+	// Never holds more than burst tokens
+	clock.Advance(time.Hour)
+	for i := 0; i < 3; i++ {
+		bucket.Reserve()
+	}
+	if d := bucket.Reserve(); d != 500*time.Millisecond {
+		t.Errorf("Got wait %s after an hour and a burst, want 500ms", d)
+	}
+}
+
+func TestTokenBucketWait(t *testing.T) {
+	clock := NewFakeClock(testStart)
+	bucket := NewTokenBucket(clock, 1, 1)
+	bucket.Wait()
+
+	done := make(chan struct{})
+	go func() {
+		bucket.Wait()
+		close(done)
+	}()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		select {
+		case <-done:
+			if elapsed := clock.Now().Sub(testStart); elapsed < time.Second {
+				t.Errorf("Waited %s for a token, want at least 1s", elapsed)
+			}
+			return
+		default:
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Wait did not return")
+		}
+		clock.Advance(100 * time.Millisecond)
+		time.Sleep(time.Millisecond)
+	}
+}
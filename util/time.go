@@ -4,15 +4,17 @@ import (
 	"fmt"
 	"regexp"
 	"strconv"
-	"strings"
 	"time"
 )
 
 var (
-	timeRegex = regexp.MustCompile("(\\d+)(?::(\\d+)(?::(\\d+))?)?")
+	timeRegex      = regexp.MustCompile("(\\d+)(?::(\\d+)(?::(\\d+))?)?")
+	timeRangeRegex = regexp.MustCompile("^\\s*(\\d+(?::\\d+(?::\\d+)?)?)\\s*-\\s*(\\d+(?::\\d+(?::\\d+)?)?)\\s*$")
 )
 
-func ParseTimeInLocation(t string, loc *time.Location) (int, error) {
+// ParseTime parses a time of day in the format HH[:MM[:SS]], returning it as
+// seconds of day.
+func ParseTime(t string) (int, error) {
 	matches := timeRegex.FindStringSubmatch(t)
 	if len(matches) == 0 {
 		return -1, fmt.Errorf("Invalid time: should be in format HH[:MM[:SS]]")
@@ -43,35 +45,96 @@ func ParseTimeInLocation(t string, loc *time.Location) (int, error) {
 			return -1, fmt.Errorf("Invalid time: SS must be in range [0, 59]")
 		}
 	}
-	utc := time.Date(2017, 1, 1, int(h), int(m), int(s), 0, loc).In(time.UTC)
-	return utc.Hour()*3600 + utc.Minute()*60 + utc.Second(), nil
+	return int(h)*3600 + int(m)*60 + int(s), nil
 }
 
-func ParseTime(t string) (int, error) {
-	return ParseTimeInLocation(t, time.UTC)
+// timeOfDayOn returns time of day t on the day that is days after the date of
+// now, in loc. A time of day skipped by a daylight saving transition is moved
+// forward by the length of the transition, and an ambiguous one resolves to
+// its first occurrence.
+func timeOfDayOn(now time.Time, days, t int, loc *time.Location) time.Time {
+	local := now.In(loc)
+	// time.Date does not say which side of a transition it picks, so try the
+	// offsets in effect a day before and a day after
+	wall := time.Date(local.Year(), local.Month(), local.Day()+days, t/3600, (t%3600)/60, t%60, 0, time.UTC)
+	_, before := wall.Add(-24 * time.Hour).In(loc).Zone()
+	_, after := wall.Add(24 * time.Hour).In(loc).Zone()
+	first := wall.Add(-time.Duration(before) * time.Second)
+	second := wall.Add(-time.Duration(after) * time.Second)
+	isWall := func(c time.Time) bool {
+		l := c.In(loc)
+		return time.Date(l.Year(), l.Month(), l.Day(), l.Hour(), l.Minute(), l.Second(), 0, time.UTC).Equal(wall)
+	}
+	switch {
+	case isWall(first) && isWall(second) && second.Before(first):
+		return second.In(loc)
+	case isWall(first):
+		return first.In(loc)
+	case isWall(second):
+		return second.In(loc)
+	}
+	// Skipped, the offset before the transition moves it forward
+	return first.In(loc)
 }
 
-func NextTime(t int) time.Time {
-	h := t / 3600
-	m := (t % 3600) / 60
-	s := t % 60
-	now := time.Now()
-	next := time.Date(now.Year(), now.Month(), now.Day(), h, m, s, 0, time.UTC)
-	if next.Before(now) {
-		next = next.Add(24 * time.Hour)
+// NextTime returns the earliest occurrence of time of day t in loc that is
+// not before now.
+func NextTime(now time.Time, t int, loc *time.Location) time.Time {
+	for days := 0; ; days++ {
+		if next := timeOfDayOn(now, days, t, loc); !next.Before(now) {
+			return next
+		}
 	}
-	return next
 }
 
 func TimeToInt(t time.Time) int {
 	return 3600*t.Hour() + 60*t.Minute() + t.Second()
 }
 
-func LoadLocation(tz string) (*time.Location, error) {
-	// parse "UTC+x"
-	if strings.HasPrefix(tz, "UTC") {
-		return time.LoadLocation(strings.Replace("UTC", tz, "GMT", 1))
+// ParseTimeRange parses a range of time of day in the format
+// HH[:MM[:SS]]-HH[:MM[:SS]], returning both ends as seconds of day.
+func ParseTimeRange(r string) (int, int, error) {
+	parts := timeRangeRegex.FindStringSubmatch(r)
+	if parts == nil {
+		return -1, -1, fmt.Errorf("Invalid time range: should be in format HH:MM-HH:MM")
+	}
+	from, err := ParseTime(parts[1])
+	if err != nil {
+		return -1, -1, err
+	}
+	to, err := ParseTime(parts[2])
+	if err != nil {
+		return -1, -1, err
+	}
+	return from, to, nil
+}
+
+// FormatTimeOfDay formats seconds of day as HH:MM, or HH:MM:SS when seconds
+// are present.
+func FormatTimeOfDay(t int) string {
+	if t%60 == 0 {
+		return fmt.Sprintf("%02d:%02d", t/3600, (t%3600)/60)
 	}
+	return fmt.Sprintf("%02d:%02d:%02d", t/3600, (t%3600)/60, t%60)
+}
 
-	return time.LoadLocation(tz)
+// InTimeRange reports whether the time of day of now, in its own location,
+// falls within [from, to), which may wrap around midnight. If it does, the
+// end of the range is returned as well.
+func InTimeRange(now time.Time, from, to int) (bool, time.Time) {
+	t := TimeToInt(now)
+	var in bool
+	if from <= to {
+		in = from <= t && t < to
+	} else {
+		in = t >= from || t < to
+	}
+	if !in {
+		return false, time.Time{}
+	}
+	end := time.Date(now.Year(), now.Month(), now.Day(), to/3600, (to%3600)/60, to%60, 0, now.Location())
+	if !end.After(now) {
+		end = end.AddDate(0, 0, 1)
+	}
+	return true, end
 }
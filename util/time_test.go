@@ -0,0 +1,143 @@
+package util
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNextTimeAcrossDaylightSaving(t *testing.T) {
+	london, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		t.Fatal(err)
+	}
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	utc := func(month time.Month, day, hour, min int) time.Time {
+		return time.Date(2026, month, day, hour, min, 0, 0, time.UTC)
+	}
+	hm := func(hour, min int) int {
+		return hour*3600 + min*60
+	}
+	tests := []struct {
+		name string
+		loc  *time.Location
+		now  time.Time
+		t    int
+		want time.Time
+	}{
+		// London skips 01:00-02:00 on March 29, 2026
+		{"london spring forward, skipped", london, utc(3, 28, 12, 0), hm(1, 30), utc(3, 29, 1, 30)},
+		{"london spring forward, after", london, utc(3, 28, 12, 0), hm(2, 30), utc(3, 29, 1, 30)},
+		{"london spring forward, before", london, utc(3, 28, 12, 0), hm(0, 30), utc(3, 29, 0, 30)},
+		{"london spring forward, midnight", london, utc(3, 29, 0, 30), hm(0, 0), utc(3, 29, 23, 0)},
+		{"london spring forward, crossing midnight", london, utc(3, 29, 22, 30), hm(0, 15), utc(3, 29, 23, 15)},
+		{"london spring forward, day after", london, utc(3, 29, 12, 0), hm(1, 30), utc(3, 30, 0, 30)},
+		// London repeats 01:00-02:00 on October 25, 2026
+		{"london fall back, ambiguous", london, utc(10, 24, 12, 0), hm(1, 30), utc(10, 25, 0, 30)},
+		{"london fall back, after", london, utc(10, 24, 12, 0), hm(2, 30), utc(10, 25, 2, 30)},
+		{"london fall back, ambiguous already passed", london, utc(10, 25, 0, 45), hm(1, 30), utc(10, 26, 1, 30)},
+		{"london fall back, crossing midnight", london, utc(10, 24, 23, 30), hm(0, 0), utc(10, 26, 0, 0)},
+		{"london fall back, day before", london, utc(10, 24, 0, 0), hm(1, 30), utc(10, 24, 0, 30)},
+		// New York skips 02:00-03:00 on March 8, 2026
+		{"new york spring forward, skipped", newYork, utc(3, 7, 12, 0), hm(2, 30), utc(3, 8, 7, 30)},
+		{"new york spring forward, before", newYork, utc(3, 7, 12, 0), hm(1, 30), utc(3, 8, 6, 30)},
+		{"new york spring forward, after", newYork, utc(3, 7, 12, 0), hm(3, 30), utc(3, 8, 7, 30)},
+		{"new york spring forward, now", newYork, utc(3, 8, 7, 30), hm(3, 30), utc(3, 8, 7, 30)},
+		{"new york spring forward, crossing midnight", newYork, utc(3, 8, 5, 30), hm(0, 0), utc(3, 9, 4, 0)},
+		// New York repeats 01:00-02:00 on November 1, 2026
+		{"new york fall back, ambiguous", newYork, utc(10, 31, 12, 0), hm(1, 30), utc(11, 1, 5, 30)},
+		{"new york fall back, after", newYork, utc(10, 31, 12, 0), hm(2, 30), utc(11, 1, 7, 30)},
+		{"new york fall back, ambiguous already passed", newYork, utc(11, 1, 5, 45), hm(1, 30), utc(11, 2, 6, 30)},
+		{"new york fall back, crossing midnight", newYork, utc(11, 2, 4, 59), hm(0, 0), utc(11, 2, 5, 0)},
+	}
+	for _, test := range tests {
+		if got := NextTime(test.now, test.t, test.loc); !got.Equal(test.want) {
+			t.Errorf("%s: NextTime(%s, %s) = %s, want %s", test.name, test.now, FormatTimeOfDay(test.t), got.UTC(), test.want)
+		}
+	}
+}
+
+func TestNextTimeIsNeverBeforeNow(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Every 15 minutes across both transitions of 2026
+	for _, start := range []time.Time{
+		time.Date(2026, 3, 7, 0, 0, 0, 0, time.UTC),
+		time.Date(2026, 10, 31, 0, 0, 0, 0, time.UTC),
+	} {
+		for now := start; now.Before(start.Add(72 * time.Hour)); now = now.Add(15 * time.Minute) {
+			for t0 := 0; t0 < 86400; t0 += 1800 {
+				next := NextTime(now, t0, newYork)
+				if next.Before(now) || next.Sub(now) > 25*time.Hour {
+					t.Fatalf("NextTime(%s, %s) = %s", now, FormatTimeOfDay(t0), next)
+				}
+			}
+		}
+	}
+}
+
+func TestParseTimeRange(t *testing.T) {
+	tests := []struct {
+		r    string
+		from int
+		to   int
+		ok   bool
+	}{
+		{"23:00-07:00", 23 * 3600, 7 * 3600, true},
+		{"9-17", 9 * 3600, 17 * 3600, true},
+		{" 00:30 - 01:45:30 ", 30 * 60, 3600 + 45*60 + 30, true},
+		{"ab12-cd3", -1, -1, false},
+		{"12:00-03:00x", -1, -1, false},
+		{"12:00", -1, -1, false},
+		{"12:00-13:00-14:00", -1, -1, false},
+		{"24:00-07:00", -1, -1, false},
+		{"23:60-07:00", -1, -1, false},
+		{"", -1, -1, false},
+	}
+	for _, test := range tests {
+		from, to, err := ParseTimeRange(test.r)
+		if (err == nil) != test.ok || from != test.from || to != test.to {
+			t.Errorf("ParseTimeRange(%q) = %d, %d, %v, want %d, %d, ok %t", test.r, from, to, err, test.from, test.to, test.ok)
+		}
+	}
+}
+
+func TestInTimeRange(t *testing.T) {
+	jakarta, err := time.LoadLocation("Asia/Jakarta")
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := func(day, hour, min int) time.Time {
+		return time.Date(2026, 3, day, hour, min, 0, 0, jakarta)
+	}
+	hm := func(hour, min int) int {
+		return hour*3600 + min*60
+	}
+	tests := []struct {
+		name     string
+		now      time.Time
+		from, to int
+		in       bool
+		end      time.Time
+	}{
+		{"within", at(7, 12, 0), hm(9, 0), hm(17, 0), true, at(7, 17, 0)},
+		{"at start", at(7, 9, 0), hm(9, 0), hm(17, 0), true, at(7, 17, 0)},
+		{"at end", at(7, 17, 0), hm(9, 0), hm(17, 0), false, time.Time{}},
+		{"before", at(7, 8, 59), hm(9, 0), hm(17, 0), false, time.Time{}},
+		{"crossing midnight, before midnight", at(7, 23, 30), hm(23, 0), hm(7, 0), true, at(8, 7, 0)},
+		{"crossing midnight, after midnight", at(8, 1, 0), hm(23, 0), hm(7, 0), true, at(8, 7, 0)},
+		{"crossing midnight, at midnight", at(8, 0, 0), hm(23, 0), hm(7, 0), true, at(8, 7, 0)},
+		{"crossing midnight, outside", at(7, 12, 0), hm(23, 0), hm(7, 0), false, time.Time{}},
+		{"until midnight", at(7, 23, 30), hm(22, 0), 0, true, at(8, 0, 0)},
+	}
+	for _, test := range tests {
+		in, end := InTimeRange(test.now, test.from, test.to)
+		if in != test.in || !end.Equal(test.end) {
+			t.Errorf("%s: InTimeRange(%s, %s, %s) = %t, %s, want %t, %s", test.name, test.now, FormatTimeOfDay(test.from), FormatTimeOfDay(test.to), in, end, test.in, test.end)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package util
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// Minutes are either after a colon, or after two digits of hours
+	utcOffsetRegex = regexp.MustCompile(`(?i)^(?:UTC|GMT)?\s*([+-])(?:(\d{1,2})(?::(\d{2}))?|(\d{2})(\d{2}))$`)
+)
+
+// LoadLocation loads a timezone given either as an IANA name (e.g.
+// Asia/Jakarta, case insensitive) or as an offset from UTC (e.g. UTC+7,
+// GMT-03:30, +0530). The name of the returned location is canonical, so it
+// can be stored and loaded again.
+func LoadLocation(tz string) (*time.Location, error) {
+	tz = strings.TrimSpace(tz)
+	switch strings.ToUpper(tz) {
+	case "UTC", "GMT", "Z":
+		return time.UTC, nil
+	case "", "LOCAL":
+		return nil, fmt.Errorf("Invalid timezone: %q", tz)
+	}
+
+	if matches := utcOffsetRegex.FindStringSubmatch(tz); matches != nil {
+		if matches[4] != "" {
+			return loadUTCOffset(matches[1], matches[4], matches[5])
+		}
+		return loadUTCOffset(matches[1], matches[2], matches[3])
+	}
+
+	for _, name := range timezoneNames {
+		if strings.EqualFold(name, tz) {
+			tz = name
+			break
+		}
+	}
+	return time.LoadLocation(tz)
+}
+
+func loadUTCOffset(sign, hours, minutes string) (*time.Location, error) {
+	h, _ := strconv.Atoi(hours)
+	m, _ := strconv.Atoi(minutes)
+	if h > 14 || m > 59 || (h == 14 && m > 0) {
+		return nil, fmt.Errorf("Invalid timezone: UTC offset must be between -14:00 and +14:00")
+	}
+	if h == 0 && m == 0 {
+		return time.UTC, nil
+	}
+	name := fmt.Sprintf("UTC%s%d", sign, h)
+	if m > 0 {
+		name = fmt.Sprintf("UTC%s%02d:%02d", sign, h, m)
+	}
+	offset := h*3600 + m*60
+	if sign == "-" {
+		offset = -offset
+	}
+	return time.FixedZone(name, offset), nil
+}
+
+// FindTimezones returns the timezones of a place given as a city (e.g. Tokyo,
+// Bandung) or a country (e.g. Indonesia). A country may have several.
+func FindTimezones(place string) []string {
+	key := strings.ToLower(strings.Join(strings.Fields(place), " "))
+	if key == "" {
+		return nil
+	}
+	if tz, ok := cityTimezones[key]; ok {
+		return []string{tz}
+	}
+	city := "/" + strings.Replace(key, " ", "_", -1)
+	for _, name := range timezoneNames {
+		if strings.HasSuffix(strings.ToLower(name), city) {
+			return []string{name}
+		}
+	}
+	return countryTimezones[key]
+}
+
+// FormatUTCOffset formats the offset of loc from UTC at t, e.g. UTC+7 or
+// UTC-03:30.
+func FormatUTCOffset(loc *time.Location, t time.Time) string {
+	_, offset := t.In(loc).Zone()
+	sign := "+"
+	if offset < 0 {
+		sign = "-"
+		offset = -offset
+	}
+	h, m := offset/3600, (offset%3600)/60
+	if m > 0 {
+		return fmt.Sprintf("UTC%s%02d:%02d", sign, h, m)
+	}
+	return fmt.Sprintf("UTC%s%d", sign, h)
+}
+
+// SuggestTimezones returns up to limit IANA timezones whose names are the
+// closest to tz, e.g. Asia/Jakarta for Asia/Jakrta. Either the full name or
+// the city alone is compared.
+func SuggestTimezones(tz string, limit int) []string {
+	tz = strings.ToLower(strings.TrimSpace(tz))
+	maxDistance := len(tz)/4 + 1
+
+	type suggestion struct {
+		name     string
+		distance int
+	}
+	var suggestions []suggestion
+	for _, name := range timezoneNames {
+		lower := strings.ToLower(name)
+		distance := levenshtein(tz, lower)
+		if i := strings.LastIndex(lower, "/"); i >= 0 {
+			if d := levenshtein(tz, lower[i+1:]); d < distance {
+				distance = d
+			}
+		}
+		if distance <= maxDistance {
+			suggestions = append(suggestions, suggestion{name, distance})
+		}
+	}
+	sort.SliceStable(suggestions, func(i, j int) bool {
+		return suggestions[i].distance < suggestions[j].distance
+	})
+
+	var res []string
+	for i := 0; i < len(suggestions) && i < limit; i++ {
+		res = append(res, suggestions[i].name)
+	}
+	return res
+}
+
+func levenshtein(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = minInt(minInt(prev[j]+1, cur[j-1]+1), prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
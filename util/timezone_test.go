@@ -0,0 +1,142 @@
+package util
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLoadLocation(t *testing.T) {
+	tests := []struct {
+		tz     string
+		name   string
+		offset int
+		ok     bool
+	}{
+		{"UTC", "UTC", 0, true},
+		{"gmt", "UTC", 0, true},
+		{"UTC+7", "UTC+7", 7 * 3600, true},
+		{"utc+07", "UTC+7", 7 * 3600, true},
+		{"UTC-5", "UTC-5", -5 * 3600, true},
+		{"GMT-03:30", "UTC-03:30", -(3*3600 + 30*60), true},
+		{"+0530", "UTC+05:30", 5*3600 + 30*60, true},
+		{"UTC-0930", "UTC-09:30", -(9*3600 + 30*60), true},
+		{"+14", "UTC+14", 14 * 3600, true},
+		{"-12", "UTC-12", -12 * 3600, true},
+		{"UTC+0", "UTC", 0, true},
+		{"UTC+15", "", 0, false},
+		{"UTC+14:30", "", 0, false},
+		{"UTC+05:60", "", 0, false},
+		{"UTC+123", "", 0, false},
+		{"Asia/Jakarta", "Asia/Jakarta", 7 * 3600, true},
+		{"asia/jakarta", "Asia/Jakarta", 7 * 3600, true},
+		{" AMERICA/ARGENTINA/BUENOS_AIRES ", "America/Argentina/Buenos_Aires", -3 * 3600, true},
+		{"Asia/Jakrta", "", 0, false},
+		{"Local", "", 0, false},
+		{"", "", 0, false},
+	}
+	// Neither Jakarta nor Buenos Aires observes daylight saving time
+	at := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, test := range tests {
+		loc, err := LoadLocation(test.tz)
+		if (err == nil) != test.ok {
+			t.Errorf("LoadLocation(%q) returned error %v, want ok %t", test.tz, err, test.ok)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if _, offset := at.In(loc).Zone(); loc.String() != test.name || offset != test.offset {
+			t.Errorf("LoadLocation(%q) = %s at offset %d, want %s at offset %d", test.tz, loc, offset, test.name, test.offset)
+		}
+		// Names are canonical, so they load the same location again
+		if again, err := LoadLocation(loc.String()); err != nil || again.String() != loc.String() {
+			t.Errorf("LoadLocation(%q) = %v, %v, want %s", loc.String(), again, err, loc)
+		}
+	}
+}
+
+func TestSuggestTimezones(t *testing.T) {
+	tests := []struct {
+		tz    string
+		first string
+	}{
+		{"Asia/Jakrta", "Asia/Jakarta"},
+		{"asia/jakarta", "Asia/Jakarta"},
+		{"Jakrta", "Asia/Jakarta"},
+		{"Europe/Londn", "Europe/London"},
+		{"America/New_Yrok", "America/New_York"},
+		{"Tokio", "Asia/Tokyo"},
+	}
+	for _, test := range tests {
+		got := SuggestTimezones(test.tz, 3)
+		if len(got) == 0 || got[0] != test.first {
+			t.Errorf("SuggestTimezones(%q) = %v, want %s first", test.tz, got, test.first)
+		}
+		if len(got) > 3 {
+			t.Errorf("SuggestTimezones(%q) = %v, want at most 3", test.tz, got)
+		}
+	}
+
+	if got := SuggestTimezones("Asia/Jakrta", 1); len(got) != 1 {
+		t.Errorf("SuggestTimezones with limit 1 = %v", got)
+	}
+	for _, tz := range []string{"xyzzy", "Mars/Olympus_Mons", ""} {
+		if got := SuggestTimezones(tz, 3); len(got) != 0 {
+			t.Errorf("SuggestTimezones(%q) = %v, want none", tz, got)
+		}
+	}
+}
+
+func TestLevenshtein(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"abc", "", 3},
+		{"", "abc", 3},
+		{"jakarta", "jakarta", 0},
+		{"jakrta", "jakarta", 1},
+		{"kitten", "sitting", 3},
+	}
+	for _, test := range tests {
+		if got := levenshtein(test.a, test.b); got != test.want {
+			t.Errorf("levenshtein(%q, %q) = %d, want %d", test.a, test.b, got, test.want)
+		}
+	}
+}
+
+func TestFindTimezones(t *testing.T) {
+	tests := []struct {
+		place string
+		want  []string
+	}{
+		{"Indonesia", []string{"Asia/Jakarta", "Asia/Pontianak", "Asia/Makassar", "Asia/Jayapura"}},
+		{"  indonesia ", []string{"Asia/Jakarta", "Asia/Pontianak", "Asia/Makassar", "Asia/Jayapura"}},
+		{"Japan", []string{"Asia/Tokyo"}},
+		{"Bandung", []string{"Asia/Jakarta"}},
+		// Cities of IANA names
+		{"Tokyo", []string{"Asia/Tokyo"}},
+		{"new  york", []string{"America/New_York"}},
+		{"Atlantis", nil},
+		{"", nil},
+	}
+	for _, test := range tests {
+		got := FindTimezones(test.place)
+		if len(got) != len(test.want) {
+			t.Errorf("FindTimezones(%q) = %v, want %v", test.place, got, test.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != test.want[i] {
+				t.Errorf("FindTimezones(%q) = %v, want %v", test.place, got, test.want)
+				break
+			}
+		}
+		for _, tz := range got {
+			if _, err := LoadLocation(tz); err != nil {
+				t.Errorf("FindTimezones(%q) found %s, which does not load: %v", test.place, tz, err)
+			}
+		}
+	}
+}